@@ -0,0 +1,158 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Verdict summarizes how a TowerAttempt was graded
+// +kubebuilder:validation:Enum=Optimal;Solved;Unsolved;Illegal
+type Verdict string
+
+const (
+	// VerdictOptimal is given to legal attempts that reach the goal in the
+	// optimal number of moves
+	VerdictOptimal Verdict = "Optimal"
+	// VerdictSolved is given to legal attempts that reach the goal with more
+	// moves than needed
+	VerdictSolved Verdict = "Solved"
+	// VerdictUnsolved is given to legal attempts that stop short of the goal
+	VerdictUnsolved Verdict = "Unsolved"
+	// VerdictIllegal is given to attempts with a move that breaks the rules
+	VerdictIllegal Verdict = "Illegal"
+)
+
+// AttemptMove is one move of a submitted solution: the top disc of From is
+// placed on To
+type AttemptMove struct {
+	// Disc is the disc being moved, 1 being the smallest. When set it must be
+	// the top disc of From
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Disc int `json:"disc,omitempty"`
+	// From is the name of the peg the disc is taken from
+	From string `json:"from"`
+	// To is the name of the peg the disc is placed on
+	To string `json:"to"`
+}
+
+// TowerAttemptSpec defines a solution submitted for a TowerChallenge
+type TowerAttemptSpec struct {
+	// Challenge is the name of the TowerChallenge the attempt solves
+	// +kubebuilder:validation:MinLength=1
+	Challenge string `json:"challenge"`
+	// Moves is the submitted solution, played in order from the initial state
+	// of the challenge
+	// +optional
+	Moves []AttemptMove `json:"moves,omitempty"`
+}
+
+// IllegalMove describes the first move of an attempt that breaks the rules
+type IllegalMove struct {
+	// Index is the position of the move in spec.moves, starting at 1
+	Index int64 `json:"index"`
+	// Move is the move as submitted
+	Move AttemptMove `json:"move"`
+	// Reason explains which rule the move breaks
+	Reason string `json:"reason"`
+}
+
+// TowerAttemptStatus defines the observed state of TowerAttempt
+type TowerAttemptStatus struct {
+	// Ready tells whether the attempt has been graded, Synced whether the last
+	// reconcile succeeded.
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// ObservedGeneration is the generation of the spec that was graded
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Verdict summarizes the grading
+	Verdict Verdict `json:"verdict,omitempty"`
+	// Legal is true when every move follows the rules of the challenge
+	Legal bool `json:"legal"`
+	// Solved is true when the moves are legal and reach the target state
+	Solved bool `json:"solved"`
+	// Moves is the number of moves submitted
+	Moves int64 `json:"moves"`
+	// OptimalMoves is the length of an optimal solution of the challenge
+	OptimalMoves int64 `json:"optimalMoves,omitempty"`
+	// ExtraMoves is the number of moves played beyond the optimum by a solved attempt
+	ExtraMoves int64 `json:"extraMoves,omitempty"`
+	// FirstIllegalMove is the move grading stopped at, when there is one
+	FirstIllegalMove *IllegalMove `json:"firstIllegalMove,omitempty"`
+	// FinalState is the board after the last legal move
+	FinalState []PegState `json:"finalState,omitempty"`
+}
+
+// ReasonChallengeNotFound is used when the TowerChallenge an attempt solves does not exist.
+const ReasonChallengeNotFound xpv1.ConditionReason = "ChallengeNotFound"
+
+// ChallengeNotFound returns a condition that indicates the attempt cannot be
+// graded because its challenge does not exist.
+func ChallengeNotFound(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeSynced,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonChallengeNotFound,
+		Message:            err.Error(),
+	}
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Challenge",type="string",JSONPath=".spec.challenge"
+//+kubebuilder:printcolumn:name="Verdict",type="string",JSONPath=".status.verdict"
+//+kubebuilder:printcolumn:name="Moves",type="integer",JSONPath=".status.moves"
+//+kubebuilder:printcolumn:name="Optimal",type="integer",JSONPath=".status.optimalMoves"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// TowerAttempt is the Schema for the towerattempts API: a solution of a
+// TowerChallenge submitted for grading
+type TowerAttempt struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TowerAttemptSpec   `json:"spec,omitempty"`
+	Status TowerAttemptStatus `json:"status,omitempty"`
+}
+
+// GetCondition of this TowerAttempt.
+func (a *TowerAttempt) GetCondition(ct xpv1.ConditionType) xpv1.Condition {
+	return a.Status.GetCondition(ct)
+}
+
+// SetConditions of this TowerAttempt.
+func (a *TowerAttempt) SetConditions(c ...xpv1.Condition) {
+	a.Status.SetConditions(c...)
+}
+
+//+kubebuilder:object:root=true
+
+// TowerAttemptList contains a list of TowerAttempt
+type TowerAttemptList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TowerAttempt `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TowerAttempt{}, &TowerAttemptList{})
+}
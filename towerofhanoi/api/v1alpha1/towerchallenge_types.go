@@ -2,46 +2,346 @@ package v1alpha1
 
 import (
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1" // Use Crossplane's common v1 for Conditions
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// Variant selects the rules a TowerChallenge is solved under
+// +kubebuilder:validation:Enum=classic;cyclic;adjacent;bicolor
+type Variant string
+
+const (
+	// VariantClassic is the textbook puzzle.
+	VariantClassic Variant = "classic"
+	// VariantCyclic only lets discs move one way round the pegs, in the order
+	// they are listed: A to B, B to C and C to A.
+	VariantCyclic Variant = "cyclic"
+	// VariantAdjacent only lets discs move between neighbouring pegs, so every
+	// move starts or ends on the middle peg.
+	VariantAdjacent Variant = "adjacent"
+	// VariantBicolor has a dark and a light disc of every size, which may rest
+	// on each other and must arrive in their original colour order.
+	VariantBicolor Variant = "bicolor"
+)
+
+// OutputKind selects how the moves of a TowerChallenge are stored
+// +kubebuilder:validation:Enum=ConfigMap;ChunkedConfigMap;Secret;TowerMove
+type OutputKind string
+
+const (
+	// OutputConfigMap writes one ConfigMap per move.
+	OutputConfigMap OutputKind = "ConfigMap"
+	// OutputChunkedConfigMap packs the moves into as few ConfigMaps as fit
+	// within output.maxChunkBytes.
+	OutputChunkedConfigMap OutputKind = "ChunkedConfigMap"
+	// OutputSecret writes one Secret per move.
+	OutputSecret OutputKind = "Secret"
+	// OutputTowerMove writes one TowerMove resource per move.
+	OutputTowerMove OutputKind = "TowerMove"
+)
+
+// Output configures where the moves of a TowerChallenge are written
+type Output struct {
+	// Kind selects the storage shape of the moves. Defaults to the cluster
+	// default, ConfigMap unless the operator configures another.
+	// +optional
+	Kind OutputKind `json:"kind,omitempty"`
+
+	// MaxChunkBytes bounds the size of the data of every ConfigMap written by
+	// the ChunkedConfigMap kind, keeping each one under the etcd object size
+	// limit. Defaults to the cluster default, 524288 (512 KiB) unless the
+	// operator configures another.
+	// +kubebuilder:validation:Minimum=4096
+	// +kubebuilder:validation:Maximum=1000000
+	// +optional
+	MaxChunkBytes int `json:"maxChunkBytes,omitempty"`
+}
+
+// MoveChunk records which moves an object written for a TowerChallenge holds
+type MoveChunk struct {
+	// Name is the name of the object
+	Name string `json:"name"`
+	// FirstMove is the index of the first move in the object
+	FirstMove int64 `json:"firstMove"`
+	// LastMove is the index of the last move in the object
+	LastMove int64 `json:"lastMove"`
+}
+
+// MaxPegs is the largest number of pegs a TowerChallenge may have; it matches
+// the bounds on spec.pegCount and spec.pegs.
+const MaxPegs = 16
+
 // TowerChallengeSpec defines the desired state of TowerChallenge
 type TowerChallengeSpec struct {
 	// Discs is the number of discs in the Tower of Hanoi challenge
 	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=63
 	Discs int `json:"discs"`
+
+	// InspectMove selects a move (1-based) to report in status.inspectedMove,
+	// computed directly without generating the rest of the solution
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	InspectMove int64 `json:"inspectMove,omitempty"`
+
+	// Variant selects the rules of the puzzle. Variants other than classic
+	// require three pegs and the default initial and target states.
+	// Defaults to the cluster default, classic unless the operator configures another.
+	// +optional
+	Variant Variant `json:"variant,omitempty"`
+
+	// PegCount is the number of pegs on the board. With more than three pegs
+	// the tower is moved with the Frame–Stewart algorithm. Defaults to the
+	// length of pegs when it is set, otherwise to the cluster default of 3.
+	// +kubebuilder:validation:Minimum=3
+	// +kubebuilder:validation:Maximum=16
+	// +optional
+	PegCount int `json:"pegCount,omitempty"`
+
+	// Pegs names each peg; the names are used in the generated steps and status.
+	// When set, it must list pegCount names. Defaults to the cluster default
+	// names when they match pegCount, otherwise to A, B, C and so on.
+	// +kubebuilder:validation:MinItems=3
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Pegs []string `json:"pegs,omitempty"`
+
+	// From is the name of the peg the tower starts on. Defaults to the first peg.
+	// +optional
+	From string `json:"from,omitempty"`
+
+	// To is the name of the peg the tower must be moved to. Defaults to the last peg.
+	// +optional
+	To string `json:"to,omitempty"`
+
+	// InitialState places every disc on a peg before the first move. Discs on
+	// each peg are listed from the bottom up and must get smaller towards the top.
+	// Only supported with three pegs. Defaults to all discs on the from peg.
+	// +optional
+	InitialState []PegState `json:"initialState,omitempty"`
+
+	// TargetState is the configuration the solution must reach, in the same
+	// form as InitialState. Defaults to all discs on the to peg.
+	// +optional
+	TargetState []PegState `json:"targetState,omitempty"`
+
+	// Output configures where the generated moves are written
+	// +optional
+	Output Output `json:"output,omitempty"`
+
+	// TargetNamespace is the namespace the generated moves are written to.
+	// TowerChallenge is cluster-scoped, so its own namespace is never used.
+	// Defaults to the cluster default, "default" unless the operator
+	// configures another. The namespace must exist.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// DeletionPolicy decides what happens to the generated moves when the
+	// TowerChallenge is deleted: Delete removes them before the challenge is
+	// gone, Orphan releases them so they outlive it.
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy xpv1.DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// PegState lists the discs stacked on a peg
+type PegState struct {
+	// Name is the label of the peg
+	Name string `json:"name"`
+	// Discs holds the disc numbers on the peg from the bottom up, 1 being the smallest disc
+	// +optional
+	Discs []int `json:"discs,omitempty"`
+}
+
+// FrameStewartSplit records one level of a Frame–Stewart solution: the top
+// Parked discs are moved aside using all Pegs pegs, then the remaining discs
+// are moved using one peg fewer
+type FrameStewartSplit struct {
+	// Discs is the number of discs moved at this level
+	Discs int `json:"discs"`
+	// Pegs is the number of pegs available at this level
+	Pegs int `json:"pegs"`
+	// Parked is the number of discs moved aside before the rest are moved
+	Parked int `json:"parked"`
+}
+
+// MoveSnapshot describes a single move and the configuration of the pegs once it has been played
+type MoveSnapshot struct {
+	// Index is the 1-based position of the move in the solution
+	Index int64 `json:"index"`
+	// Disc is the number of the disc being moved
+	Disc int `json:"disc"`
+	// From is the peg the disc is taken from
+	From string `json:"from"`
+	// To is the peg the disc is placed on
+	To string `json:"to"`
+	// Pegs is the configuration of every peg after the move
+	Pegs []PegState `json:"pegs"`
+}
+
+// MoveRecordVersion identifies the current format of MoveRecord. It changes
+// whenever a field is removed or its meaning changes.
+const MoveRecordVersion = "v1"
+
+// MoveRecord is the machine-readable form of a move, written to every
+// generated artifact alongside the human-readable step
+type MoveRecord struct {
+	// Version identifies the record format, see MoveRecordVersion
+	Version string `json:"version"`
+
+	MoveSnapshot `json:",inline"`
 }
 
 // TowerChallengeStatus defines the observed state of TowerChallenge
 type TowerChallengeStatus struct {
-	// Standard condition fields used by Crossplane to report the observed state of the resource.
-	Conditions []xpv1.Condition `json:"conditions,omitempty"`
+	// Standard condition fields used by Crossplane to report the observed state
+	// of the resource: Ready tells whether every move has been written, Synced
+	// whether the last reconcile succeeded.
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// ObservedGeneration is the generation of the spec the moves were last written for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// SpecHash is a digest of the spec the moves were last written for
+	SpecHash string `json:"specHash,omitempty"`
 
-	// Steps represent the moves to solve the problem, formatted as a series of instructions
-	Steps   []string `json:"steps,omitempty"`
-	Message string   `json:"message,omitempty"`
+	// Steps represent the moves to solve the problem, formatted as a series of instructions.
+	// Long solutions only keep their first and last moves, see StepsOmitted
+	Steps []string `json:"steps,omitempty"`
+	// StepsOmitted is the number of moves left out of Steps between its first and last moves
+	StepsOmitted int64 `json:"stepsOmitted,omitempty"`
+	// StepsDigest is the SHA-256 digest of every step of the solution, one per line
+	StepsDigest string `json:"stepsDigest,omitempty"`
+	// SolutionDigest is the SHA-256 digest of every move record of the solution,
+	// encoded as JSON one per line. Every generated artifact carries it in its
+	// webapp.hanoi.com/solution-digest annotation, next to the digest of its own
+	// data in webapp.hanoi.com/data-digest
+	SolutionDigest string `json:"solutionDigest,omitempty"`
 
-	// Phase represents the current phase of the operation (e.g., "Pending", "Completed")
+	Message string `json:"message,omitempty"`
+
+	// Phase represents the current phase of the operation (e.g., "Publishing", "Completed", "Degraded", "Failed")
 	Phase string `json:"phase,omitempty"`
 	// ConfigMapsCreated indicates whether the config maps were successfully created
 	ConfigMapsCreated bool `json:"configMapsCreated"`
-	// ConfigMapNames lists the names of the created config maps
+	// ConfigMapNames is no longer set, as the list grew with every move. The
+	// ConfigMaps are named by ArtifactPrefix and ArtifactCount instead.
+	//
+	// Deprecated: use ArtifactPrefix and ArtifactCount
 	ConfigMapNames []string `json:"configMapNames,omitempty"`
+	// TargetNamespace is the namespace the moves were last written to
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// OutputKind is the kind of the objects the moves were last written to
+	OutputKind OutputKind `json:"outputKind,omitempty"`
+	// ArtifactPrefix is the prefix of the names of the objects holding the
+	// moves, whatever their kind. Object i, counting from 1, is named
+	// ArtifactPrefix followed by i
+	ArtifactPrefix string `json:"artifactPrefix,omitempty"`
+	// MovesPerArtifact is the number of consecutive moves each object holds:
+	// object i holds the moves from (i-1)*MovesPerArtifact+1 to
+	// i*MovesPerArtifact, or to the last move
+	MovesPerArtifact int64 `json:"movesPerArtifact,omitempty"`
+	// ArtifactCount is the number of objects written so far, named from
+	// ArtifactPrefix followed by 1 to ArtifactPrefix followed by ArtifactCount
+	ArtifactCount int64 `json:"artifactCount,omitempty"`
+	// PublishedMoves is the number of moves written so far. Moves are written
+	// in batches, and publication resumes from here after an interruption.
+	PublishedMoves int64 `json:"publishedMoves,omitempty"`
+	// Progress is PublishedMoves as a percentage of TotalMoves
+	Progress string `json:"progress,omitempty"`
+	// MissingMoves lists the objects the last attempt failed to write, with
+	// the moves each should hold. Set while the phase is Degraded.
+	MissingMoves []MoveChunk `json:"missingMoves,omitempty"`
 	// StartTime is the time when the operation started
 	StartTime metav1.Time `json:"startTime,omitempty"`
 	// EndTime is the time when the operation completed
 	EndTime metav1.Time `json:"endTime,omitempty"`
+	// VerifiedTime is when every object holding the moves was last compared
+	// with the solution. In between, each object is only checked against the
+	// data digest it carries
+	VerifiedTime metav1.Time `json:"verifiedTime,omitempty"`
 	// ErrorMessage contains details of any errors that occurred
 	ErrorMessage string `json:"errorMessage,omitempty"`
+	// TotalMoves is the number of moves in the solution
+	TotalMoves int64 `json:"totalMoves,omitempty"`
+	// OptimalMoves is the length of an optimal solution under the rules of spec.variant
+	OptimalMoves int64 `json:"optimalMoves,omitempty"`
+	// InspectedMove reports the move selected by spec.inspectMove
+	InspectedMove *MoveSnapshot `json:"inspectedMove,omitempty"`
+	// Splits records the Frame–Stewart split chosen at each level when more than three pegs are used
+	Splits []FrameStewartSplit `json:"splits,omitempty"`
+}
+
+// Reasons for the Ready and Synced conditions of a TowerChallenge.
+const (
+	// ReasonSolving is used while the solution is being computed.
+	ReasonSolving xpv1.ConditionReason = "Solving"
+	// ReasonWritingMoves is used while the moves are being written out.
+	ReasonWritingMoves xpv1.ConditionReason = "WritingMoves"
+	// ReasonValidationFailed is used when the spec cannot be solved as given.
+	ReasonValidationFailed xpv1.ConditionReason = "ValidationFailed"
+	// ReasonInvalidState is used when spec.initialState or spec.targetState is not a legal configuration.
+	ReasonInvalidState xpv1.ConditionReason = "InvalidState"
+)
+
+// Solving returns a condition that indicates the solution is being computed.
+func Solving() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSolving,
+	}
+}
+
+// WritingMoves returns a condition that indicates the moves are being written out.
+func WritingMoves() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonWritingMoves,
+	}
+}
+
+// ValidationFailed returns a condition that indicates the spec was rejected.
+func ValidationFailed(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeSynced,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonValidationFailed,
+		Message:            err.Error(),
+	}
+}
+
+// InvalidState returns a condition that indicates the requested start or
+// target configuration cannot be solved.
+func InvalidState(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               xpv1.TypeSynced,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInvalidState,
+		Message:            err.Error(),
+	}
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:scope=Cluster
 //+kubebuilder:printcolumn:name="Discs",type="integer",JSONPath=".spec.discs"
+//+kubebuilder:printcolumn:name="Variant",type="string",JSONPath=".spec.variant"
+//+kubebuilder:printcolumn:name="Namespace",type="string",JSONPath=".status.targetNamespace"
+//+kubebuilder:printcolumn:name="Moves",type="integer",JSONPath=".status.totalMoves"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+//+kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
 //+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Progress",type="string",JSONPath=".status.progress"
 //+kubebuilder:printcolumn:name="StartTime",type="date",JSONPath=".status.startTime"
 //+kubebuilder:printcolumn:name="EndTime",type="date",JSONPath=".status.endTime"
+//+kubebuilder:printcolumn:name="DeletionPolicy",type="string",JSONPath=".spec.deletionPolicy",priority=1
 
 // TowerChallenge is the Schema for the towerchallenges API
 type TowerChallenge struct {
@@ -52,6 +352,16 @@ type TowerChallenge struct {
 	Status TowerChallengeStatus `json:"status,omitempty"`
 }
 
+// GetCondition of this TowerChallenge.
+func (tc *TowerChallenge) GetCondition(ct xpv1.ConditionType) xpv1.Condition {
+	return tc.Status.GetCondition(ct)
+}
+
+// SetConditions of this TowerChallenge.
+func (tc *TowerChallenge) SetConditions(c ...xpv1.Condition) {
+	tc.Status.SetConditions(c...)
+}
+
 //+kubebuilder:object:root=true
 
 // TowerChallengeList contains a list of TowerChallenge
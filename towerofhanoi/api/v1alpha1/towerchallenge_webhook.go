@@ -0,0 +1,326 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"hanoi.com/towerofhanoi/pkg/solver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultMaxDiscs is the disc ceiling enforced when the operator does not
+// configure one. A 20-disc challenge already takes over a million moves.
+const DefaultMaxDiscs = 20
+
+// DefaultMaxMoves is the move ceiling enforced when the operator does not
+// configure one: the length of a classic 20-disc solution. The variants
+// reach it with fewer discs.
+const DefaultMaxMoves = 1<<DefaultMaxDiscs - 1
+
+// DefaultMaxObjects is the object ceiling enforced when the operator does
+// not configure one: the length of a classic 16-disc solution, written one
+// move per object. The ChunkedConfigMap kind packs many moves per object
+// and reaches it with many more discs.
+const DefaultMaxObjects = 1<<16 - 1
+
+// MaxMoveCount returns the number of moves the longest solution of a
+// challenge with spec can take, whichever pegs it starts and ends on. With
+// custom states it is the length of the classic solution, which no
+// solution between two states exceeds. Counts that do not fit in a uint64
+// saturate at math.MaxUint64.
+func MaxMoveCount(spec TowerChallengeSpec) uint64 {
+	n := spec.Discs
+	pegs := spec.PegCount
+	if pegs == 0 {
+		pegs = len(spec.Pegs)
+	}
+	switch {
+	case spec.Variant == VariantCyclic:
+		return solver.CyclicCount(n, false)
+	case spec.Variant == VariantAdjacent:
+		return solver.AdjacentCount(n, true)
+	case spec.Variant == VariantBicolor:
+		return solver.BicolorCount(n)
+	case pegs > 3 && len(spec.InitialState) == 0 && len(spec.TargetState) == 0:
+		return solver.FrameStewartCount(n, pegs)
+	default:
+		return solver.MoveCount(n)
+	}
+}
+
+// MaxObjectCount returns the number of objects the moves of a challenge with
+// spec can be written to, and false when that depends on more than the spec.
+// The kinds holding one move per object take as many objects as
+// MaxMoveCount; how many moves fit in a chunk of the ChunkedConfigMap kind
+// follows from the longest record of the solution.
+func MaxObjectCount(spec TowerChallengeSpec) (uint64, bool) {
+	if spec.Output.Kind == OutputChunkedConfigMap {
+		return 0, false
+	}
+	return MaxMoveCount(spec), true
+}
+
+// log is for logging in this package.
+var towerchallengelog = logf.Log.WithName("towerchallenge-resource")
+
+// TowerChallengeValidator rejects TowerChallenges the controller should not
+// attempt to solve.
+type TowerChallengeValidator struct {
+	// MaxDiscs is the largest disc count accepted. Zero means DefaultMaxDiscs.
+	MaxDiscs int
+	// MaxMoves is the largest number of moves a challenge may take to
+	// solve, as counted by MaxMoveCount. Zero means DefaultMaxMoves.
+	MaxMoves uint64
+	// MaxObjects is the largest number of objects the moves of a challenge
+	// may be written to, as counted by MaxObjectCount. Zero means
+	// DefaultMaxObjects.
+	MaxObjects uint64
+}
+
+// TowerChallengeDefaults holds the operator-wide settings applied to fields
+// a TowerChallenge leaves empty. It is loaded from the file given to the
+// manager's --defaults-config flag.
+type TowerChallengeDefaults struct {
+	// Variant is used when spec.variant is empty and the challenge can be
+	// played with it: three pegs and the default initial and target states.
+	Variant Variant `json:"variant,omitempty"`
+	// PegCount is used when neither spec.pegCount nor spec.pegs is set and
+	// the challenge can be played with it: the classic rules and the default
+	// initial and target states.
+	PegCount int `json:"pegCount,omitempty"`
+	// Pegs is used when spec.pegs is empty and the names match the peg count.
+	Pegs []string `json:"pegs,omitempty"`
+	// Output fills the empty fields of spec.output.
+	Output Output `json:"output,omitempty"`
+	// TargetNamespace is used when spec.targetNamespace is empty.
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+}
+
+// Validate reports defaults that no TowerChallenge could be admitted with.
+func (d TowerChallengeDefaults) Validate() error {
+	if d.PegCount != 0 && (d.PegCount < 3 || d.PegCount > MaxPegs) {
+		return fmt.Errorf("pegCount must be between 3 and %d, got %d", MaxPegs, d.PegCount)
+	}
+	if len(d.Pegs) != 0 && (len(d.Pegs) < 3 || len(d.Pegs) > MaxPegs) {
+		return fmt.Errorf("between 3 and %d pegs must be named, got %d", MaxPegs, len(d.Pegs))
+	}
+	switch d.Variant {
+	case "", VariantClassic, VariantCyclic, VariantAdjacent, VariantBicolor:
+	default:
+		return fmt.Errorf("unknown variant %q", d.Variant)
+	}
+	switch d.Output.Kind {
+	case "", OutputConfigMap, OutputChunkedConfigMap, OutputSecret, OutputTowerMove:
+	default:
+		return fmt.Errorf("unknown output kind %q", d.Output.Kind)
+	}
+	if d.Output.MaxChunkBytes != 0 && (d.Output.MaxChunkBytes < 4096 || d.Output.MaxChunkBytes > 1000000) {
+		return fmt.Errorf("output.maxChunkBytes must be between 4096 and 1000000, got %d", d.Output.MaxChunkBytes)
+	}
+	if d.TargetNamespace != "" {
+		if errs := validation.IsDNS1123Label(d.TargetNamespace); len(errs) > 0 {
+			return fmt.Errorf("targetNamespace %q is not a valid namespace name: %s", d.TargetNamespace, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// SetupTowerChallengeWebhookWithManager registers the TowerChallenge webhooks with the manager.
+func SetupTowerChallengeWebhookWithManager(mgr ctrl.Manager, d *TowerChallengeDefaulter, v *TowerChallengeValidator) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&TowerChallenge{}).
+		WithDefaulter(d).
+		WithValidator(v).
+		Complete()
+}
+
+// TowerChallengeDefaulter fills the empty fields of a TowerChallenge from
+// the operator-wide defaults.
+type TowerChallengeDefaulter struct {
+	Defaults TowerChallengeDefaults
+}
+
+//+kubebuilder:webhook:path=/mutate-webapp-hanoi-com-v1alpha1-towerchallenge,mutating=true,failurePolicy=fail,sideEffects=None,groups=webapp.hanoi.com,resources=towerchallenges,verbs=create;update,versions=v1alpha1,name=mtowerchallenge.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomDefaulter = &TowerChallengeDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type
+func (d *TowerChallengeDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	tc, ok := obj.(*TowerChallenge)
+	if !ok {
+		return fmt.Errorf("expected a TowerChallenge but got %T", obj)
+	}
+	towerchallengelog.Info("default", "name", tc.Name)
+
+	spec := &tc.Spec
+	if spec.PegCount == 0 {
+		spec.PegCount = len(spec.Pegs)
+		classic := spec.Variant == "" || spec.Variant == VariantClassic
+		if spec.PegCount == 0 && classic && len(spec.InitialState) == 0 && len(spec.TargetState) == 0 {
+			spec.PegCount = d.Defaults.PegCount
+		}
+	}
+	if len(spec.Pegs) == 0 && len(d.Defaults.Pegs) == max(spec.PegCount, 3) {
+		spec.Pegs = append([]string(nil), d.Defaults.Pegs...)
+	}
+	if spec.Variant == "" && d.Defaults.Variant != "" {
+		if spec.PegCount <= 3 && len(spec.InitialState) == 0 && len(spec.TargetState) == 0 {
+			spec.Variant = d.Defaults.Variant
+		}
+	}
+	if spec.Output.Kind == "" {
+		spec.Output.Kind = d.Defaults.Output.Kind
+	}
+	if spec.Output.MaxChunkBytes == 0 {
+		spec.Output.MaxChunkBytes = d.Defaults.Output.MaxChunkBytes
+	}
+	if spec.TargetNamespace == "" {
+		spec.TargetNamespace = d.Defaults.TargetNamespace
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-webapp-hanoi-com-v1alpha1-towerchallenge,mutating=false,failurePolicy=fail,sideEffects=None,groups=webapp.hanoi.com,resources=towerchallenges,verbs=create;update,versions=v1alpha1,name=vtowerchallenge.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &TowerChallengeValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *TowerChallengeValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	tc, ok := obj.(*TowerChallenge)
+	if !ok {
+		return nil, fmt.Errorf("expected a TowerChallenge but got %T", obj)
+	}
+	towerchallengelog.Info("validate create", "name", tc.Name)
+	return nil, v.validate(tc, nil)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *TowerChallengeValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	tc, ok := newObj.(*TowerChallenge)
+	if !ok {
+		return nil, fmt.Errorf("expected a TowerChallenge but got %T", newObj)
+	}
+	old, ok := oldObj.(*TowerChallenge)
+	if !ok {
+		return nil, fmt.Errorf("expected a TowerChallenge but got %T", oldObj)
+	}
+	towerchallengelog.Info("validate update", "name", tc.Name)
+	return nil, v.validate(tc, old)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *TowerChallengeValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *TowerChallengeValidator) maxDiscs() int {
+	if v.MaxDiscs <= 0 {
+		return DefaultMaxDiscs
+	}
+	return v.MaxDiscs
+}
+
+func (v *TowerChallengeValidator) maxMoves() uint64 {
+	if v.MaxMoves == 0 {
+		return DefaultMaxMoves
+	}
+	return v.MaxMoves
+}
+
+func (v *TowerChallengeValidator) maxObjects() uint64 {
+	if v.MaxObjects == 0 {
+		return DefaultMaxObjects
+	}
+	return v.MaxObjects
+}
+
+// validate checks tc, which replaces old on an update and is created when
+// old is nil.
+func (v *TowerChallengeValidator) validate(tc, old *TowerChallenge) error {
+	var allErrs field.ErrorList
+	if v.checkCeilings(tc, old) {
+		if limit := v.maxDiscs(); tc.Spec.Discs > limit {
+			atLimit := tc.Spec
+			atLimit.Discs = limit
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "discs"), tc.Spec.Discs,
+				fmt.Sprintf("this cluster accepts at most %d discs, which already take %s moves to solve under the rules of this challenge",
+					limit, countText(MaxMoveCount(atLimit)))))
+		}
+		if limit, moves := v.maxMoves(), MaxMoveCount(tc.Spec); moves > limit {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "discs"), tc.Spec.Discs,
+				fmt.Sprintf("solving %d discs can take %s moves, more than the %d this cluster accepts", tc.Spec.Discs, countText(moves), limit)))
+		}
+		if objects, ok := MaxObjectCount(tc.Spec); ok && objects > v.maxObjects() {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "discs"), tc.Spec.Discs,
+				fmt.Sprintf("writing the moves of %d discs one per object can take %s objects, more than the %d this cluster accepts; the %s output kind packs many moves per object",
+					tc.Spec.Discs, countText(objects), v.maxObjects(), OutputChunkedConfigMap)))
+		}
+	}
+	if tc.Spec.PegCount > MaxPegs {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "pegCount"), tc.Spec.PegCount,
+			fmt.Sprintf("at most %d pegs are supported", MaxPegs)))
+	}
+	if len(tc.Spec.Pegs) > MaxPegs {
+		allErrs = append(allErrs, field.TooMany(field.NewPath("spec", "pegs"), len(tc.Spec.Pegs), MaxPegs))
+	}
+	if ns := tc.Spec.TargetNamespace; ns != "" {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "targetNamespace"), ns, msg))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("TowerChallenge").GroupKind(), tc.Name, allErrs)
+}
+
+// checkCeilings reports whether the disc, move and object ceilings apply to
+// tc, which replaces old on an update. Updates that leave the size of the
+// challenge and its output kind alone, such as the removal of the finalizer, are let through even when
+// the ceilings were lowered after tc was created, as are updates to a
+// challenge that is being deleted.
+func (v *TowerChallengeValidator) checkCeilings(tc, old *TowerChallenge) bool {
+	if old == nil {
+		return true
+	}
+	if !tc.DeletionTimestamp.IsZero() {
+		return false
+	}
+	return tc.Spec.Discs != old.Spec.Discs || tc.Spec.Output.Kind != old.Spec.Output.Kind ||
+		MaxMoveCount(tc.Spec) > MaxMoveCount(old.Spec)
+}
+
+// countText renders a move count from MaxMoveCount, which saturates at
+// math.MaxUint64.
+func countText(moves uint64) string {
+	if moves == math.MaxUint64 {
+		return "more than " + strconv.FormatUint(moves-1, 10)
+	}
+	return strconv.FormatUint(moves, 10)
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("TowerChallenge Webhook", func() {
+	challenge := func(discs int) *TowerChallenge {
+		return &TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample"},
+			Spec:       TowerChallengeSpec{Discs: discs},
+		}
+	}
+
+	It("accepts disc counts up to the configured ceiling", func() {
+		v := &TowerChallengeValidator{MaxDiscs: 8}
+		_, err := v.ValidateCreate(context.Background(), challenge(8))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects disc counts above the configured ceiling with a reason", func() {
+		v := &TowerChallengeValidator{MaxDiscs: 8}
+		_, err := v.ValidateUpdate(context.Background(), challenge(8), challenge(9))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("spec.discs: Invalid value: 9: this cluster accepts at most 8 discs, which already take 255 moves to solve")))
+	})
+
+	It("counts the moves of the variant in the rejection", func() {
+		tc := challenge(9)
+		tc.Spec.Variant = VariantAdjacent
+		_, err := (&TowerChallengeValidator{MaxDiscs: 8}).ValidateCreate(context.Background(), tc)
+		Expect(err).To(MatchError(ContainSubstring("at most 8 discs, which already take 6560 moves")))
+	})
+
+	It("lets updates through that keep the disc count after the ceiling was lowered", func() {
+		v := &TowerChallengeValidator{MaxDiscs: 8}
+		old, tc := challenge(10), challenge(10)
+		tc.Finalizers = nil
+		old.Finalizers = []string{"webapp.hanoi.com/cleanup"}
+		_, err := v.ValidateUpdate(context.Background(), old, tc)
+		Expect(err).NotTo(HaveOccurred())
+
+		tc.Spec.Variant = VariantAdjacent
+		_, err = v.ValidateUpdate(context.Background(), old, tc)
+		Expect(err).To(HaveOccurred())
+
+		now := metav1.Now()
+		tc.DeletionTimestamp = &now
+		_, err = v.ValidateUpdate(context.Background(), old, tc)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects a target namespace that is not a valid name", func() {
+		tc := challenge(3)
+		tc.Spec.TargetNamespace = "Tower_Challenge"
+		_, err := (&TowerChallengeValidator{}).ValidateCreate(context.Background(), tc)
+		Expect(err).To(MatchError(ContainSubstring("spec.targetNamespace")))
+	})
+
+	It("limits each variant by the number of moves it takes", func() {
+		v := &TowerChallengeValidator{}
+		tc := challenge(12)
+		tc.Spec.Variant = VariantAdjacent
+		tc.Spec.Output.Kind = OutputChunkedConfigMap
+		_, err := v.ValidateCreate(context.Background(), tc)
+		Expect(err).NotTo(HaveOccurred())
+
+		tc.Spec.Discs = 13
+		_, err = v.ValidateCreate(context.Background(), tc)
+		Expect(err).To(MatchError(ContainSubstring("solving 13 discs can take 1594322 moves, more than the 1048575 this cluster accepts")))
+
+		tc.Spec.Variant, tc.Spec.Discs = VariantCyclic, 63
+		_, err = (&TowerChallengeValidator{MaxDiscs: 63}).ValidateCreate(context.Background(), tc)
+		Expect(err).To(MatchError(ContainSubstring("can take more than 18446744073709551614 moves")))
+	})
+
+	It("rejects more pegs than are supported", func() {
+		tc := challenge(3)
+		tc.Spec.PegCount = MaxPegs + 1
+		_, err := (&TowerChallengeValidator{}).ValidateCreate(context.Background(), tc)
+		Expect(err).To(MatchError(ContainSubstring("spec.pegCount")))
+
+		tc = challenge(3)
+		for i := 0; i <= MaxPegs; i++ {
+			tc.Spec.Pegs = append(tc.Spec.Pegs, string(rune('a'+i)))
+		}
+		_, err = (&TowerChallengeValidator{}).ValidateCreate(context.Background(), tc)
+		Expect(err).To(MatchError(ContainSubstring("spec.pegs: Too many")))
+	})
+
+	It("falls back to the default ceiling", func() {
+		v := &TowerChallengeValidator{}
+		chunked := func(discs int) *TowerChallenge {
+			tc := challenge(discs)
+			tc.Spec.Output.Kind = OutputChunkedConfigMap
+			return tc
+		}
+		_, err := v.ValidateCreate(context.Background(), chunked(DefaultMaxDiscs))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = v.ValidateCreate(context.Background(), chunked(DefaultMaxDiscs+1))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("limits the objects of the kinds that write one move per object", func() {
+		v := &TowerChallengeValidator{}
+		_, err := v.ValidateCreate(context.Background(), challenge(16))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = v.ValidateCreate(context.Background(), challenge(17))
+		Expect(err).To(MatchError(ContainSubstring("writing the moves of 17 discs one per object can take 131071 objects, more than the 65535 this cluster accepts")))
+
+		tc := challenge(17)
+		tc.Spec.Output.Kind = OutputTowerMove
+		_, err = v.ValidateCreate(context.Background(), tc)
+		Expect(err).To(HaveOccurred())
+
+		tc.Spec.Output.Kind = OutputChunkedConfigMap
+		_, err = v.ValidateCreate(context.Background(), tc)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = (&TowerChallengeValidator{MaxObjects: 1 << 17}).ValidateCreate(context.Background(), challenge(17))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("checks the object ceiling when the output kind changes", func() {
+		v := &TowerChallengeValidator{}
+		old, tc := challenge(18), challenge(18)
+		old.Spec.Output.Kind = OutputChunkedConfigMap
+		tc.Spec.Output.Kind = OutputChunkedConfigMap
+		_, err := v.ValidateUpdate(context.Background(), old, tc)
+		Expect(err).NotTo(HaveOccurred())
+
+		tc.Spec.Output.Kind = OutputSecret
+		_, err = v.ValidateUpdate(context.Background(), old, tc)
+		Expect(err).To(MatchError(ContainSubstring("one per object")))
+	})
+})
+
+var _ = Describe("TowerChallenge defaulting", func() {
+	defaults := TowerChallengeDefaults{
+		Variant:  VariantCyclic,
+		PegCount: 3,
+		Pegs:     []string{"left", "middle", "right"},
+		Output:   Output{Kind: OutputChunkedConfigMap, MaxChunkBytes: 65536},
+
+		TargetNamespace: "tower-challenge",
+	}
+
+	It("fills empty fields from the cluster defaults", func() {
+		tc := &TowerChallenge{Spec: TowerChallengeSpec{Discs: 3}}
+		Expect((&TowerChallengeDefaulter{Defaults: defaults}).Default(context.Background(), tc)).To(Succeed())
+		Expect(tc.Spec.PegCount).To(Equal(3))
+		Expect(tc.Spec.Pegs).To(Equal([]string{"left", "middle", "right"}))
+		Expect(tc.Spec.Variant).To(Equal(VariantCyclic))
+		Expect(tc.Spec.Output).To(Equal(Output{Kind: OutputChunkedConfigMap, MaxChunkBytes: 65536}))
+		Expect(tc.Spec.TargetNamespace).To(Equal("tower-challenge"))
+	})
+
+	It("keeps fields set on the challenge", func() {
+		tc := &TowerChallenge{Spec: TowerChallengeSpec{
+			Discs:   3,
+			Variant: VariantClassic,
+			Pegs:    []string{"a", "b", "c", "d"},
+			Output:  Output{Kind: OutputSecret},
+		}}
+		Expect((&TowerChallengeDefaulter{Defaults: defaults}).Default(context.Background(), tc)).To(Succeed())
+		Expect(tc.Spec.PegCount).To(Equal(4))
+		Expect(tc.Spec.Pegs).To(Equal([]string{"a", "b", "c", "d"}))
+		Expect(tc.Spec.Variant).To(Equal(VariantClassic))
+		Expect(tc.Spec.Output.Kind).To(Equal(OutputSecret))
+	})
+
+	It("skips defaults the challenge cannot use", func() {
+		tc := &TowerChallenge{Spec: TowerChallengeSpec{Discs: 3, PegCount: 4}}
+		Expect((&TowerChallengeDefaulter{Defaults: defaults}).Default(context.Background(), tc)).To(Succeed())
+		Expect(tc.Spec.Pegs).To(BeEmpty())
+		Expect(tc.Spec.Variant).To(BeEmpty())
+	})
+
+	It("only fills the peg count of classic challenges with the default states", func() {
+		fourPegs := TowerChallengeDefaults{PegCount: 4}
+		tc := &TowerChallenge{Spec: TowerChallengeSpec{Discs: 3, Variant: VariantCyclic}}
+		Expect((&TowerChallengeDefaulter{Defaults: fourPegs}).Default(context.Background(), tc)).To(Succeed())
+		Expect(tc.Spec.PegCount).To(BeZero())
+
+		tc = &TowerChallenge{Spec: TowerChallengeSpec{Discs: 3, InitialState: []PegState{{Name: "A", Discs: []int{1, 2, 3}}}}}
+		Expect((&TowerChallengeDefaulter{Defaults: fourPegs}).Default(context.Background(), tc)).To(Succeed())
+		Expect(tc.Spec.PegCount).To(BeZero())
+
+		tc = &TowerChallenge{Spec: TowerChallengeSpec{Discs: 3}}
+		Expect((&TowerChallengeDefaulter{Defaults: fourPegs}).Default(context.Background(), tc)).To(Succeed())
+		Expect(tc.Spec.PegCount).To(Equal(4))
+	})
+
+	It("rejects defaults no challenge could use", func() {
+		Expect(defaults.Validate()).To(Succeed())
+		Expect(TowerChallengeDefaults{PegCount: 2}.Validate()).To(MatchError(ContainSubstring("pegCount")))
+		Expect(TowerChallengeDefaults{Variant: "spiral"}.Validate()).To(MatchError(ContainSubstring("unknown variant")))
+		Expect(TowerChallengeDefaults{Output: Output{Kind: "Blob"}}.Validate()).To(MatchError(ContainSubstring("unknown output kind")))
+	})
+})
@@ -0,0 +1,58 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TowerMoveSpec defines a single move of a TowerChallenge solution
+type TowerMoveSpec struct {
+	// Challenge is the name of the TowerChallenge the move belongs to
+	Challenge string `json:"challenge"`
+	// Step is the move in human-readable form
+	Step string `json:"step"`
+	// Record is the move in machine-readable form
+	Record MoveRecord `json:"record"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Challenge",type="string",JSONPath=".spec.challenge"
+//+kubebuilder:printcolumn:name="Index",type="integer",JSONPath=".spec.record.index"
+//+kubebuilder:printcolumn:name="Step",type="string",JSONPath=".spec.step"
+
+// TowerMove is the Schema for the towermoves API, written by the
+// TowerChallenge controller when spec.output.kind is TowerMove
+type TowerMove struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TowerMoveSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// TowerMoveList contains a list of TowerMove
+type TowerMoveList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TowerMove `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TowerMove{}, &TowerMoveList{})
+}
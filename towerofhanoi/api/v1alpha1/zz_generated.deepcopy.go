@@ -21,16 +21,256 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"github.com/crossplane/crossplane-runtime/apis/common/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AttemptMove) DeepCopyInto(out *AttemptMove) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttemptMove.
+func (in *AttemptMove) DeepCopy() *AttemptMove {
+	if in == nil {
+		return nil
+	}
+	out := new(AttemptMove)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FrameStewartSplit) DeepCopyInto(out *FrameStewartSplit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FrameStewartSplit.
+func (in *FrameStewartSplit) DeepCopy() *FrameStewartSplit {
+	if in == nil {
+		return nil
+	}
+	out := new(FrameStewartSplit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IllegalMove) DeepCopyInto(out *IllegalMove) {
+	*out = *in
+	out.Move = in.Move
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IllegalMove.
+func (in *IllegalMove) DeepCopy() *IllegalMove {
+	if in == nil {
+		return nil
+	}
+	out := new(IllegalMove)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MoveChunk) DeepCopyInto(out *MoveChunk) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MoveChunk.
+func (in *MoveChunk) DeepCopy() *MoveChunk {
+	if in == nil {
+		return nil
+	}
+	out := new(MoveChunk)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MoveRecord) DeepCopyInto(out *MoveRecord) {
+	*out = *in
+	in.MoveSnapshot.DeepCopyInto(&out.MoveSnapshot)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MoveRecord.
+func (in *MoveRecord) DeepCopy() *MoveRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(MoveRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MoveSnapshot) DeepCopyInto(out *MoveSnapshot) {
+	*out = *in
+	if in.Pegs != nil {
+		in, out := &in.Pegs, &out.Pegs
+		*out = make([]PegState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MoveSnapshot.
+func (in *MoveSnapshot) DeepCopy() *MoveSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(MoveSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Output) DeepCopyInto(out *Output) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Output.
+func (in *Output) DeepCopy() *Output {
+	if in == nil {
+		return nil
+	}
+	out := new(Output)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PegState) DeepCopyInto(out *PegState) {
+	*out = *in
+	if in.Discs != nil {
+		in, out := &in.Discs, &out.Discs
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PegState.
+func (in *PegState) DeepCopy() *PegState {
+	if in == nil {
+		return nil
+	}
+	out := new(PegState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerAttempt) DeepCopyInto(out *TowerAttempt) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerAttempt.
+func (in *TowerAttempt) DeepCopy() *TowerAttempt {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerAttempt)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TowerAttempt) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerAttemptList) DeepCopyInto(out *TowerAttemptList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TowerAttempt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerAttemptList.
+func (in *TowerAttemptList) DeepCopy() *TowerAttemptList {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerAttemptList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TowerAttemptList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerAttemptSpec) DeepCopyInto(out *TowerAttemptSpec) {
+	*out = *in
+	if in.Moves != nil {
+		in, out := &in.Moves, &out.Moves
+		*out = make([]AttemptMove, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerAttemptSpec.
+func (in *TowerAttemptSpec) DeepCopy() *TowerAttemptSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerAttemptSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerAttemptStatus) DeepCopyInto(out *TowerAttemptStatus) {
+	*out = *in
+	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
+	if in.FirstIllegalMove != nil {
+		in, out := &in.FirstIllegalMove, &out.FirstIllegalMove
+		*out = new(IllegalMove)
+		**out = **in
+	}
+	if in.FinalState != nil {
+		in, out := &in.FinalState, &out.FinalState
+		*out = make([]PegState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerAttemptStatus.
+func (in *TowerAttemptStatus) DeepCopy() *TowerAttemptStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerAttemptStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TowerChallenge) DeepCopyInto(out *TowerChallenge) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -52,6 +292,43 @@ func (in *TowerChallenge) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerChallengeDefaulter) DeepCopyInto(out *TowerChallengeDefaulter) {
+	*out = *in
+	in.Defaults.DeepCopyInto(&out.Defaults)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerChallengeDefaulter.
+func (in *TowerChallengeDefaulter) DeepCopy() *TowerChallengeDefaulter {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerChallengeDefaulter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerChallengeDefaults) DeepCopyInto(out *TowerChallengeDefaults) {
+	*out = *in
+	if in.Pegs != nil {
+		in, out := &in.Pegs, &out.Pegs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Output = in.Output
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerChallengeDefaults.
+func (in *TowerChallengeDefaults) DeepCopy() *TowerChallengeDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerChallengeDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TowerChallengeList) DeepCopyInto(out *TowerChallengeList) {
 	*out = *in
@@ -87,6 +364,26 @@ func (in *TowerChallengeList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TowerChallengeSpec) DeepCopyInto(out *TowerChallengeSpec) {
 	*out = *in
+	if in.Pegs != nil {
+		in, out := &in.Pegs, &out.Pegs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InitialState != nil {
+		in, out := &in.InitialState, &out.InitialState
+		*out = make([]PegState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TargetState != nil {
+		in, out := &in.TargetState, &out.TargetState
+		*out = make([]PegState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Output = in.Output
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerChallengeSpec.
@@ -102,13 +399,7 @@ func (in *TowerChallengeSpec) DeepCopy() *TowerChallengeSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TowerChallengeStatus) DeepCopyInto(out *TowerChallengeStatus) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
+	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
 	if in.Steps != nil {
 		in, out := &in.Steps, &out.Steps
 		*out = make([]string, len(*in))
@@ -119,8 +410,24 @@ func (in *TowerChallengeStatus) DeepCopyInto(out *TowerChallengeStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.MissingMoves != nil {
+		in, out := &in.MissingMoves, &out.MissingMoves
+		*out = make([]MoveChunk, len(*in))
+		copy(*out, *in)
+	}
 	in.StartTime.DeepCopyInto(&out.StartTime)
 	in.EndTime.DeepCopyInto(&out.EndTime)
+	in.VerifiedTime.DeepCopyInto(&out.VerifiedTime)
+	if in.InspectedMove != nil {
+		in, out := &in.InspectedMove, &out.InspectedMove
+		*out = new(MoveSnapshot)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Splits != nil {
+		in, out := &in.Splits, &out.Splits
+		*out = make([]FrameStewartSplit, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerChallengeStatus.
@@ -132,3 +439,92 @@ func (in *TowerChallengeStatus) DeepCopy() *TowerChallengeStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerChallengeValidator) DeepCopyInto(out *TowerChallengeValidator) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerChallengeValidator.
+func (in *TowerChallengeValidator) DeepCopy() *TowerChallengeValidator {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerChallengeValidator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerMove) DeepCopyInto(out *TowerMove) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerMove.
+func (in *TowerMove) DeepCopy() *TowerMove {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerMove)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TowerMove) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerMoveList) DeepCopyInto(out *TowerMoveList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TowerMove, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerMoveList.
+func (in *TowerMoveList) DeepCopy() *TowerMoveList {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerMoveList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TowerMoveList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TowerMoveSpec) DeepCopyInto(out *TowerMoveSpec) {
+	*out = *in
+	in.Record.DeepCopyInto(&out.Record)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TowerMoveSpec.
+func (in *TowerMoveSpec) DeepCopy() *TowerMoveSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TowerMoveSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -20,6 +20,7 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -33,6 +34,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/yaml"
 
 	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
 	"hanoi.com/towerofhanoi/internal/controller"
@@ -57,6 +59,13 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var maxDiscs int
+	var maxMoves uint64
+	var maxObjects uint64
+	var defaultsConfig string
+	var publishBatchSize int
+	var stepsPreview int
+	var verifyPeriod time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -66,6 +75,20 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.IntVar(&maxDiscs, "max-discs", webappv1alpha1.DefaultMaxDiscs,
+		"The largest number of discs a TowerChallenge may have, enforced by the admission webhook and the controller.")
+	flag.Uint64Var(&maxMoves, "max-moves", webappv1alpha1.DefaultMaxMoves,
+		"The largest number of moves a TowerChallenge may take to solve under its variant, enforced by the admission webhook and the controller.")
+	flag.Uint64Var(&maxObjects, "max-objects", webappv1alpha1.DefaultMaxObjects,
+		"The largest number of objects the moves of a TowerChallenge may be written to, enforced by the admission webhook and the controller.")
+	flag.StringVar(&defaultsConfig, "defaults-config", "",
+		"Path to a YAML file of TowerChallenge defaults applied by the admission webhook.")
+	flag.IntVar(&publishBatchSize, "publish-batch-size", 500,
+		"The largest number of move objects a single reconcile writes before requeueing.")
+	flag.IntVar(&stepsPreview, "steps-preview", 10,
+		"The number of moves kept at each end of status.steps; the moves in between are only counted and digested.")
+	flag.DurationVar(&verifyPeriod, "verify-period", 6*time.Hour,
+		"How often the moves of a completed TowerChallenge are compared with its solution; in between, each object is only checked against the digest it carries.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -96,6 +119,7 @@ func main() {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache:  controller.CacheOptions(),
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -123,12 +147,41 @@ func main() {
 	}
 
 	if err = (&controller.TowerChallengeReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		Recorder:         mgr.GetEventRecorderFor("towerchallenge-controller"),
+		PublishBatchSize: publishBatchSize,
+		StepsPreview:     stepsPreview,
+		VerifyPeriod:     verifyPeriod,
+		MaxDiscs:         maxDiscs,
+		MaxMoves:         maxMoves,
+		MaxObjects:       maxObjects,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "TowerChallenge")
 		os.Exit(1)
 	}
+	if err = (&controller.TowerAttemptReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("towerattempt-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TowerAttempt")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		defaults, err := loadDefaults(defaultsConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load defaults", "path", defaultsConfig)
+			os.Exit(1)
+		}
+		if err = webappv1alpha1.SetupTowerChallengeWebhookWithManager(mgr,
+			&webappv1alpha1.TowerChallengeDefaulter{Defaults: defaults},
+			&webappv1alpha1.TowerChallengeValidator{MaxDiscs: maxDiscs, MaxMoves: maxMoves, MaxObjects: maxObjects},
+		); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "TowerChallenge")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -146,3 +199,20 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// loadDefaults reads the TowerChallenge defaults from path, returning empty
+// defaults when no path is given.
+func loadDefaults(path string) (webappv1alpha1.TowerChallengeDefaults, error) {
+	var defaults webappv1alpha1.TowerChallengeDefaults
+	if path == "" {
+		return defaults, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return defaults, err
+	}
+	if err := yaml.UnmarshalStrict(data, &defaults); err != nil {
+		return defaults, err
+	}
+	return defaults, defaults.Validate()
+}
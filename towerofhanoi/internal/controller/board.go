@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/solver"
+)
+
+// defaultPegCount is used when a challenge sets neither spec.pegCount nor
+// spec.pegs.
+const defaultPegCount = 3
+
+// pegCount is the number of pegs of spec: spec.pegCount, otherwise the
+// length of spec.pegs when it is set, otherwise defaultPegCount.
+func pegCount(spec webappv1alpha1.TowerChallengeSpec) int {
+	switch {
+	case spec.PegCount != 0:
+		return spec.PegCount
+	case len(spec.Pegs) != 0:
+		return len(spec.Pegs)
+	default:
+		return defaultPegCount
+	}
+}
+
+// board maps the peg names of a challenge onto solver pegs, which are
+// numbered in the order the names are listed.
+type board struct {
+	names  []string
+	source solver.Peg
+	target solver.Peg
+	// spares lists the remaining pegs in order; on a three-peg board
+	// spares[0] is the auxiliary peg.
+	spares []solver.Peg
+}
+
+// newBoard resolves spec.pegCount, spec.pegs, spec.from and spec.to. The tower
+// moves from the first peg to the last one unless the selectors say otherwise.
+func newBoard(spec webappv1alpha1.TowerChallengeSpec) (board, error) {
+	count := pegCount(spec)
+	if count < 3 {
+		return board{}, fmt.Errorf("pegCount must be at least 3, got %d", count)
+	}
+	names := spec.Pegs
+	if len(names) == 0 {
+		names = defaultPegNames(count)
+	}
+	if len(names) != count {
+		return board{}, fmt.Errorf("%d pegs must be named, got %d", count, len(names))
+	}
+	b := board{names: names}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == "" {
+			return board{}, errors.New("peg names must not be empty")
+		}
+		if seen[name] {
+			return board{}, fmt.Errorf("peg %q is named more than once", name)
+		}
+		seen[name] = true
+	}
+
+	b.source, b.target = 0, solver.Peg(len(names)-1)
+	if spec.From != "" {
+		if b.source = b.index(spec.From); b.source < 0 {
+			return board{}, fmt.Errorf("from references undefined peg %q", spec.From)
+		}
+	}
+	if spec.To != "" {
+		if b.target = b.index(spec.To); b.target < 0 {
+			return board{}, fmt.Errorf("to references undefined peg %q", spec.To)
+		}
+	}
+	if b.source == b.target {
+		return board{}, fmt.Errorf("from and to must be different pegs, both are %q", b.names[b.source])
+	}
+	for i := range names {
+		if p := solver.Peg(i); p != b.source && p != b.target {
+			b.spares = append(b.spares, p)
+		}
+	}
+	return b, nil
+}
+
+// defaultPegNames labels pegs A, B, C and so on, numbering them once the
+// alphabet runs out.
+func defaultPegNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		if i < 26 {
+			names[i] = string(rune('A' + i))
+		} else {
+			names[i] = fmt.Sprintf("P%d", i+1)
+		}
+	}
+	return names
+}
+
+// index returns the solver peg with the given name, or -1 if there is none.
+func (b board) index(name string) solver.Peg {
+	for i, n := range b.names {
+		if n == name {
+			return solver.Peg(i)
+		}
+	}
+	return -1
+}
+
+// resolveStates returns the per-disc peg positions of the start and target
+// configurations, defaulting to a full tower on the source and target pegs.
+func (b board) resolveStates(spec webappv1alpha1.TowerChallengeSpec) ([]solver.Peg, []solver.Peg, error) {
+	start := fullTower(spec.Discs, b.source)
+	if len(spec.InitialState) > 0 {
+		var err error
+		if start, err = b.parseState(spec.InitialState, spec.Discs); err != nil {
+			return nil, nil, fmt.Errorf("invalid initialState: %w", err)
+		}
+	}
+	goal := fullTower(spec.Discs, b.target)
+	if len(spec.TargetState) > 0 {
+		var err error
+		if goal, err = b.parseState(spec.TargetState, spec.Discs); err != nil {
+			return nil, nil, fmt.Errorf("invalid targetState: %w", err)
+		}
+	}
+	return start, goal, nil
+}
+
+// parseState converts per-peg stacks into per-disc peg positions, checking
+// that every disc appears exactly once and never rests on a smaller disc.
+func (b board) parseState(pegs []webappv1alpha1.PegState, discs int) ([]solver.Peg, error) {
+	state := make([]solver.Peg, discs)
+	placed := make([]bool, discs)
+	for _, p := range pegs {
+		peg := b.index(p.Name)
+		if peg < 0 {
+			return nil, fmt.Errorf("unknown peg %q", p.Name)
+		}
+		for i, disc := range p.Discs {
+			if disc < 1 || disc > discs {
+				return nil, fmt.Errorf("disc %d on peg %s does not exist", disc, p.Name)
+			}
+			if placed[disc-1] {
+				return nil, fmt.Errorf("disc %d is placed more than once", disc)
+			}
+			if i > 0 && disc > p.Discs[i-1] {
+				return nil, fmt.Errorf("disc %d is placed on smaller disc %d on peg %s", disc, p.Discs[i-1], p.Name)
+			}
+			placed[disc-1] = true
+			state[disc-1] = peg
+		}
+	}
+	for i, ok := range placed {
+		if !ok {
+			return nil, fmt.Errorf("disc %d is not placed on any peg", i+1)
+		}
+	}
+	return state, nil
+}
+
+// snapshot describes move k, given the discs on each peg once it has been played.
+func (b board) snapshot(k int64, move solver.Move, stacks [][]int) *webappv1alpha1.MoveSnapshot {
+	return &webappv1alpha1.MoveSnapshot{
+		Index: k,
+		Disc:  move.Disk,
+		From:  b.names[move.From],
+		To:    b.names[move.To],
+		Pegs:  b.pegStates(stacks),
+	}
+}
+
+// pegStates names the discs on each peg, listed from the bottom up.
+func (b board) pegStates(stacks [][]int) []webappv1alpha1.PegState {
+	pegs := make([]webappv1alpha1.PegState, len(b.names))
+	for i, name := range b.names {
+		// Copy the stack so later moves do not change the state.
+		pegs[i] = webappv1alpha1.PegState{Name: name, Discs: append([]int(nil), stacks[i]...)}
+	}
+	return pegs
+}
+
+// stacks converts per-disc peg positions into the discs on each peg,
+// listing the largest disc first.
+func (b board) stacks(state []solver.Peg) [][]int {
+	stacks := make([][]int, len(b.names))
+	for disc := len(state); disc >= 1; disc-- {
+		p := state[disc-1]
+		stacks[p] = append(stacks[p], disc)
+	}
+	return stacks
+}
+
+func fullTower(discs int, peg solver.Peg) []solver.Peg {
+	state := make([]solver.Peg, discs)
+	for i := range state {
+		state[i] = peg
+	}
+	return state
+}
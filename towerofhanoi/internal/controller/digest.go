@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Annotations set on every generated artifact so that consumers can verify
+// it. solutionDigestAnnotation holds the digest of the whole solution, as in
+// status.solutionDigest and computed by summarize; dataDigestAnnotation
+// holds the digest of the moves stored in the artifact itself, as computed
+// by objectDigest.
+const (
+	solutionDigestAnnotation = "webapp.hanoi.com/solution-digest"
+	dataDigestAnnotation     = "webapp.hanoi.com/data-digest"
+)
+
+// objectDigest is the SHA-256 digest of the moves held by obj: the JSON
+// encoding of the data of a ConfigMap or Secret, or of the spec of a
+// TowerMove. It reports false for any other kind of object.
+func objectDigest(obj client.Object) (string, bool, error) {
+	var content any
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		content = o.Data
+	case *corev1.Secret:
+		content = o.Data
+	case *webappv1alpha1.TowerMove:
+		content = o.Spec
+	default:
+		return "", false, nil
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), true, nil
+}
+
+// setDigests records on obj the digest of its data and the digest of the
+// solution of tc.
+func setDigests(obj client.Object, tc *webappv1alpha1.TowerChallenge) error {
+	digest, ok, err := objectDigest(obj)
+	if err != nil || !ok {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[dataDigestAnnotation] = digest
+	if tc.Status.SolutionDigest != "" {
+		annotations[solutionDigestAnnotation] = tc.Status.SolutionDigest
+	}
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// checkDigests reports whether obj still holds the data it was written with,
+// going by the digest it carries, and carries the digest of the solution of
+// tc. It builds no move, so it is cheap enough for every reconcile, but it
+// misses data changed along with its digest; verifyDigests catches that.
+func checkDigests(obj client.Object, tc *webappv1alpha1.TowerChallenge) (bool, error) {
+	digest, ok, err := objectDigest(obj)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return true, nil
+	}
+	annotations := obj.GetAnnotations()
+	return annotations[dataDigestAnnotation] == digest &&
+		annotations[solutionDigestAnnotation] == tc.Status.SolutionDigest, nil
+}
+
+// verifyDigests reports whether obj holds the same moves as expected, as
+// built from the solution of tc, and carries the digests of those moves and
+// of the solution. The digest obj carries is not trusted on its own: it
+// would still match data changed along with it.
+func verifyDigests(obj, expected client.Object, tc *webappv1alpha1.TowerChallenge) (bool, error) {
+	digest, ok, err := objectDigest(obj)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return true, nil
+	}
+	want, _, err := objectDigest(expected)
+	if err != nil {
+		return false, err
+	}
+	annotations := obj.GetAnnotations()
+	return digest == want && annotations[dataDigestAnnotation] == want &&
+		annotations[solutionDigestAnnotation] == tc.Status.SolutionDigest, nil
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Operations counted by artifactOperations and artifactFailures.
+// Server-side apply does not tell a create from an update without reading the
+// object first, so the writes of objects the challenge has already published
+// count as updates and the others as creates.
+const (
+	operationCreated = "created"
+	operationUpdated = "updated"
+	operationDeleted = "deleted"
+	operationApply   = "apply"
+	operationDelete  = "delete"
+)
+
+var (
+	// solveDuration observes how long solving a challenge takes.
+	solveDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "towerofhanoi_solve_duration_seconds",
+		Help:    "Time taken to solve a TowerChallenge, by number of discs.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 12),
+	}, []string{"discs"})
+
+	// reconcileDuration observes how long a whole reconcile takes, including
+	// the writes of a batch of moves.
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "towerofhanoi_reconcile_duration_seconds",
+		Help:    "Time taken to reconcile a TowerChallenge, by number of discs.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"discs"})
+
+	// artifactOperations counts the objects holding moves that were created,
+	// updated or deleted.
+	artifactOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "towerofhanoi_artifacts_total",
+		Help: "Objects holding moves created, updated or deleted, by kind.",
+	}, []string{"kind", "operation"})
+
+	// artifactFailures counts the writes and deletes of objects holding moves
+	// that failed.
+	artifactFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "towerofhanoi_artifact_failures_total",
+		Help: "Failed applies and deletes of objects holding moves, by kind.",
+	}, []string{"kind", "operation"})
+
+	// outstandingMoves tracks the moves of each challenge that are still to
+	// be published.
+	outstandingMoves = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "towerofhanoi_outstanding_moves",
+		Help: "Moves of a TowerChallenge that have not been published yet.",
+	}, []string{"challenge"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		solveDuration,
+		reconcileDuration,
+		artifactOperations,
+		artifactFailures,
+		outstandingMoves,
+	)
+}
+
+// discsLabel is the value of the discs label for a challenge with discs discs.
+func discsLabel(discs int) string {
+	return strconv.Itoa(discs)
+}
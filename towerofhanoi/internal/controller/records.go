@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// Keys of the data written for every move. moveKey holds the human-readable
+// step; the other keys hold the same move as a versioned MoveRecord.
+const (
+	moveKey     = "move"
+	moveJSONKey = "move.json"
+	moveYAMLKey = "move.yaml"
+)
+
+// moveFormatAnnotation records the MoveRecord version of generated artifacts.
+const moveFormatAnnotation = "webapp.hanoi.com/move-format"
+
+// moveSource is the MoveSource of a solution on a board. It keeps the discs
+// on each peg where the last call to Records stopped, so that records asked
+// for in order are built without playing the moves before them again.
+type moveSource struct {
+	b   board
+	sol solution
+	// stacks holds the discs on each peg once played moves have been played;
+	// it is nil until the first call to Records.
+	stacks [][]int
+	played uint64
+}
+
+func newMoveSource(b board, sol solution) *moveSource {
+	return &moveSource{b: b, sol: sol}
+}
+
+func (s *moveSource) Total() int64 {
+	return int64(s.sol.total)
+}
+
+func (s *moveSource) Records(first, last int64) ([]webappv1alpha1.MoveRecord, error) {
+	if first < 1 || last < first-1 || last > s.Total() {
+		return nil, fmt.Errorf("moves %d to %d are not between 1 and %d", first, last, s.Total())
+	}
+	if err := s.seek(uint64(first - 1)); err != nil {
+		return nil, err
+	}
+	records := make([]webappv1alpha1.MoveRecord, 0, last-first+1)
+	for k := first; k <= last; k++ {
+		m, err := s.sol.move(s.b, uint64(k))
+		if err != nil {
+			return nil, err
+		}
+		play(s.stacks, m)
+		s.played++
+		records = append(records, webappv1alpha1.MoveRecord{
+			Version:      webappv1alpha1.MoveRecordVersion,
+			MoveSnapshot: *s.b.snapshot(k, m, s.stacks),
+		})
+	}
+	return records, nil
+}
+
+// seek brings stacks to the position after the first k moves. Moves are
+// played on from the current position when it comes before k; otherwise
+// the position is worked out again from the start.
+func (s *moveSource) seek(k uint64) error {
+	if s.stacks != nil && s.played == k {
+		return nil
+	}
+	if s.stacks != nil && s.played < k && !s.sol.classic {
+		for ; s.played < k; s.played++ {
+			play(s.stacks, s.sol.moves[s.played])
+		}
+		return nil
+	}
+	stacks, err := s.sol.stacksAfter(s.b, k)
+	if err != nil {
+		return err
+	}
+	s.stacks, s.played = stacks, k
+	return nil
+}
+
+// LongestRecord describes the last move of the largest disc between the pegs
+// with the longest names, with every peg holding a disc and every disc of
+// the solution listed on the first peg besides. Its encoding is at least as
+// long as that of any real record.
+func (s *moveSource) LongestRecord() webappv1alpha1.MoveRecord {
+	longest := ""
+	pegs := make([]webappv1alpha1.PegState, len(s.b.names))
+	for i, name := range s.b.names {
+		if len(name) > len(longest) {
+			longest = name
+		}
+		pegs[i] = webappv1alpha1.PegState{Name: name, Discs: []int{s.sol.discs}}
+	}
+	for _, stack := range s.sol.start {
+		pegs[0].Discs = append(pegs[0].Discs, stack...)
+	}
+	return webappv1alpha1.MoveRecord{
+		Version: webappv1alpha1.MoveRecordVersion,
+		MoveSnapshot: webappv1alpha1.MoveSnapshot{
+			Index: s.Total(),
+			Disc:  s.sol.discs,
+			From:  longest,
+			To:    longest,
+			Pegs:  pegs,
+		},
+	}
+}
+
+// moveText renders a move as a human-readable step.
+func moveText(rec webappv1alpha1.MoveRecord) string {
+	return fmt.Sprintf("Move disk %d from %s to %s", rec.Disc, rec.From, rec.To)
+}
+
+// summaryBlock is the number of records summarize builds at a time.
+const summaryBlock = 4096
+
+// summary describes a whole solution in status: the first and last steps,
+// the number of steps left out in between, the digest of the steps, each
+// followed by a newline, and the digest of the records, each encoded as JSON
+// and followed by a newline.
+type summary struct {
+	steps          []string
+	omitted        int64
+	stepsDigest    string
+	solutionDigest string
+}
+
+// summarize builds every record of moves once, a block at a time so that the
+// whole solution is never held in memory, and keeps the first and last n
+// steps.
+func summarize(moves MoveSource, n int) (summary, error) {
+	total, keep := moves.Total(), int64(n)
+	stepsHash, recordsHash := sha256.New(), sha256.New()
+	var sum summary
+	for first := int64(1); first <= total; first += summaryBlock {
+		records, err := moves.Records(first, min(first+summaryBlock-1, total))
+		if err != nil {
+			return summary{}, err
+		}
+		for _, rec := range records {
+			text := moveText(rec)
+			fmt.Fprintln(stepsHash, text)
+			if rec.Index <= keep || rec.Index > total-keep {
+				sum.steps = append(sum.steps, text)
+			}
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return summary{}, err
+			}
+			recordsHash.Write(data)
+			recordsHash.Write([]byte("\n"))
+		}
+	}
+	if total > 2*keep {
+		sum.omitted = total - 2*keep
+	}
+	sum.stepsDigest = fmt.Sprintf("sha256:%x", stepsHash.Sum(nil))
+	sum.solutionDigest = fmt.Sprintf("sha256:%x", recordsHash.Sum(nil))
+	return sum, nil
+}
+
+// moveData returns the ConfigMap data describing a move.
+func moveData(rec webappv1alpha1.MoveRecord) (map[string]string, error) {
+	asJSON, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	asYAML, err := yaml.JSONToYAML(asJSON)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		moveKey:     moveText(rec),
+		moveJSONKey: string(asJSON),
+		moveYAMLKey: string(asYAML),
+	}, nil
+}
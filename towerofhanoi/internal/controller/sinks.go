@@ -0,0 +1,750 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// challengeLabel is set on every object written for a TowerChallenge to the
+// name of the challenge.
+const challengeLabel = "challenge"
+
+// releasedAnnotation is set, to the name of the challenge, on the objects a
+// TowerChallenge released with the Orphan policy in place of challengeLabel,
+// so that a later challenge of the same name neither adopts nor prunes them.
+const releasedAnnotation = "webapp.hanoi.com/released-from"
+
+// CacheOptions limits the ConfigMaps and Secrets the manager caches to those
+// labelled for a TowerChallenge. The controllers watch and list no others,
+// and caching every Secret in the cluster would hold credentials they have no
+// use for.
+func CacheOptions() cache.Options {
+	// challengeLabel is a valid label key, so the requirement cannot fail.
+	labelled, _ := labels.NewRequirement(challengeLabel, selection.Exists, nil)
+	selector := labels.NewSelector().Add(*labelled)
+	return cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Label: selector},
+			&corev1.Secret{}:    {Label: selector},
+		},
+	}
+}
+
+// fieldManager owns every field the controller writes, on the generated
+// objects as well as on TowerChallenge status.
+const fieldManager = "towerofhanoi-controller"
+
+// fieldOwner is passed on every write so that a single field manager is used.
+var fieldOwner = client.FieldOwner(fieldManager)
+
+// applyMove labels obj for tc, makes tc its controller, records the digests
+// of its data and of the solution and applies it, counting the write as
+// operation.
+func applyMove(ctx context.Context, c client.Client, tc *webappv1alpha1.TowerChallenge, obj client.Object, operation string) error {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[challengeLabel] = tc.Name
+	obj.SetLabels(labels)
+	if err := controllerutil.SetControllerReference(tc, obj, c.Scheme()); err != nil {
+		return err
+	}
+	if err := setDigests(obj, tc); err != nil {
+		return err
+	}
+	return applyObject(ctx, c, obj, operation)
+}
+
+// applyObject writes obj with server-side apply, so the fields it sets are
+// owned by fieldManager. When another manager has changed one of them the
+// conflict is logged and the fields are taken back, correcting the drift.
+// The outcome is counted in artifactOperations as operation, or in
+// artifactFailures.
+func applyObject(ctx context.Context, c client.Client, obj client.Object, operation string) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	err = c.Patch(ctx, obj, client.Apply, fieldOwner)
+	if kerrors.IsConflict(err) {
+		log.FromContext(ctx).Info("Correcting fields changed by another manager", gvk.Kind, obj.GetName(), "conflict", err.Error())
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		obj.SetResourceVersion("")
+		obj.SetManagedFields(nil)
+		err = c.Patch(ctx, obj, client.Apply, fieldOwner, client.ForceOwnership)
+	}
+	if err != nil {
+		artifactFailures.WithLabelValues(gvk.Kind, operationApply).Inc()
+		return err
+	}
+	artifactOperations.WithLabelValues(gvk.Kind, operation).Inc()
+	return nil
+}
+
+// MoveSource builds the records of the moves of a solution on demand, so
+// that a batch of moves can be written without building the records before it.
+type MoveSource interface {
+	// Total is the number of moves in the solution.
+	Total() int64
+	// Records returns the records of moves first to last, counting from 1.
+	// Asking for consecutive ranges in order is cheapest.
+	Records(first, last int64) ([]webappv1alpha1.MoveRecord, error)
+	// LongestRecord returns a record that takes at least as many bytes to
+	// encode as any record of the solution, for sinks that pack several
+	// moves into one object.
+	LongestRecord() webappv1alpha1.MoveRecord
+}
+
+// MoveSink stores the moves generated for a TowerChallenge.
+type MoveSink interface {
+	// ObjectKind is the kind of object the sink writes. Sinks that write the
+	// same kind share the objects they prune.
+	ObjectKind() string
+	// Layout returns the prefix of the names of the objects holding the
+	// moves of tc and the number of consecutive moves each of them holds:
+	// object i, counting from 1, is named prefix followed by i and holds
+	// the moves from (i-1)*perObject+1 on.
+	Layout(tc *webappv1alpha1.TowerChallenge, moves MoveSource) (prefix string, perObject int64, err error)
+	// Publish writes the objects holding the moves that come after the move
+	// at index after, which ends an object, in order, stopping after limit
+	// objects when limit is positive. It returns the objects written with
+	// the range of moves in each. The objects whose names existing reports
+	// were written before and are counted as updated, the others as created;
+	// a nil existing reports none.
+	Publish(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource, after int64, limit int, existing func(name string) bool) ([]webappv1alpha1.MoveChunk, error)
+	// Prune deletes the objects controlled by tc whose names keep does not
+	// report. A nil keep deletes them all.
+	Prune(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, keep func(name string) bool) error
+	// Adopt makes tc the controller of the objects it wrote before owner
+	// references were set. Objects that merely carry its label are left alone.
+	Adopt(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error
+	// Release removes tc as the controller of its objects so that they are
+	// not garbage-collected with it.
+	Release(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error
+	// Stale returns the numbers of the objects listed in the status of tc
+	// that are missing or whose data no longer matches the digest they
+	// carry. It builds no move.
+	Stale(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) ([]int64, error)
+	// Verify returns the numbers of the objects listed in the status of tc
+	// that are missing or do not hold the part of moves they should, as laid
+	// out in its status. Unlike Stale it builds every object from moves, so
+	// it also catches data changed along with its digest.
+	Verify(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource) ([]int64, error)
+}
+
+// PublishError is returned by a MoveSink that could not write every object of
+// a batch. The objects that were written are still returned alongside it.
+type PublishError struct {
+	// Missing lists the objects that could not be written with the moves
+	// they should hold.
+	Missing []webappv1alpha1.MoveChunk
+	// Errors holds the reason each object is missing, in the same order.
+	Errors []error
+}
+
+func (e *PublishError) Error() string {
+	names := make([]string, len(e.Missing))
+	for i, m := range e.Missing {
+		names[i] = m.Name
+	}
+	return fmt.Sprintf("failed to write %s: %v", strings.Join(names, ", "), utilerrors.NewAggregate(e.Errors))
+}
+
+// Unwrap returns the reasons the objects are missing.
+func (e *PublishError) Unwrap() []error {
+	return e.Errors
+}
+
+func (e *PublishError) add(chunk webappv1alpha1.MoveChunk, err error) {
+	e.Missing = append(e.Missing, chunk)
+	e.Errors = append(e.Errors, err)
+}
+
+// orNil returns e, or nil when nothing is missing.
+func (e *PublishError) orNil() error {
+	if len(e.Missing) == 0 {
+		return nil
+	}
+	return e
+}
+
+// newMoveSinks returns the built-in sink for every output kind. Pruning is
+// reported through recorder.
+func newMoveSinks(c client.Client, recorder record.EventRecorder) map[webappv1alpha1.OutputKind]MoveSink {
+	return map[webappv1alpha1.OutputKind]MoveSink{
+		webappv1alpha1.OutputConfigMap:        &configMapSink{Client: c, Recorder: recorder},
+		webappv1alpha1.OutputChunkedConfigMap: &chunkedConfigMapSink{Client: c, Recorder: recorder},
+		webappv1alpha1.OutputSecret:           &secretSink{Client: c, Recorder: recorder},
+		webappv1alpha1.OutputTowerMove:        &towerMoveSink{Client: c, Recorder: recorder},
+	}
+}
+
+// moveObjectName is the name of the object holding move index of tc.
+func moveObjectName(tc *webappv1alpha1.TowerChallenge, index int64) string {
+	return fmt.Sprintf("%s-move-%d", tc.Name, index)
+}
+
+// artifactName is the name of object i holding the moves of tc, as laid out
+// in its status.
+func artifactName(tc *webappv1alpha1.TowerChallenge, i int64) string {
+	return fmt.Sprintf("%s%d", tc.Status.ArtifactPrefix, i)
+}
+
+// writtenArtifact reports whether name is one of the objects holding the
+// moves of tc that its status lists as written.
+func writtenArtifact(tc *webappv1alpha1.TowerChallenge, name string) bool {
+	suffix, ok := strings.CutPrefix(name, tc.Status.ArtifactPrefix)
+	if !ok || tc.Status.ArtifactPrefix == "" {
+		return false
+	}
+	i, err := strconv.ParseInt(suffix, 10, 64)
+	return err == nil && i >= 1 && i <= tc.Status.ArtifactCount && artifactName(tc, i) == name
+}
+
+// objectBuilder returns the object named name in namespace that holds the
+// moves of records for tc.
+type objectBuilder func(tc *webappv1alpha1.TowerChallenge, namespace, name string, records []webappv1alpha1.MoveRecord) (client.Object, error)
+
+// publishObjects writes the objects laid out by prefix and perObject that
+// hold the moves after the move at index after, in order, stopping after
+// limit objects when limit is positive. Each object is built from its
+// records by build, and its write counted as an update when existing
+// reports its name. An object that cannot be written does not stop the
+// others; the failures are returned together as a *PublishError.
+func publishObjects(ctx context.Context, c client.Client, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource, prefix string, perObject, after int64, limit int, existing func(name string) bool, build objectBuilder) ([]webappv1alpha1.MoveChunk, error) {
+	var written []webappv1alpha1.MoveChunk
+	failed := &PublishError{}
+	total := moves.Total()
+	for first := after + 1; first <= total; first += perObject {
+		if limit > 0 && len(written)+len(failed.Missing) == limit {
+			break
+		}
+		chunk := webappv1alpha1.MoveChunk{
+			Name:      fmt.Sprintf("%s%d", prefix, (first-1)/perObject+1),
+			FirstMove: first,
+			LastMove:  min(first+perObject-1, total),
+		}
+		records, err := moves.Records(chunk.FirstMove, chunk.LastMove)
+		var obj client.Object
+		if err == nil {
+			obj, err = build(tc, namespace, chunk.Name, records)
+		}
+		if err == nil {
+			operation := operationCreated
+			if existing != nil && existing(chunk.Name) {
+				operation = operationUpdated
+			}
+			err = applyMove(ctx, c, tc, obj, operation)
+		}
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to write moves", "name", chunk.Name)
+			failed.add(chunk, err)
+			continue
+		}
+		written = append(written, chunk)
+	}
+	return written, failed.orNil()
+}
+
+// configMapSink writes one ConfigMap per move.
+type configMapSink struct {
+	client.Client
+	Recorder record.EventRecorder
+}
+
+func (s *configMapSink) ObjectKind() string { return "ConfigMap" }
+
+func (s *configMapSink) Layout(tc *webappv1alpha1.TowerChallenge, moves MoveSource) (string, int64, error) {
+	return tc.Name + "-move-", 1, nil
+}
+
+func (s *configMapSink) Publish(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource, after int64, limit int, existing func(name string) bool) ([]webappv1alpha1.MoveChunk, error) {
+	prefix, perObject, err := s.Layout(tc, moves)
+	if err != nil {
+		return nil, err
+	}
+	return publishObjects(ctx, s.Client, tc, namespace, moves, prefix, perObject, after, limit, existing, moveConfigMap)
+}
+
+// moveConfigMap builds the ConfigMap holding the single move of records.
+func moveConfigMap(tc *webappv1alpha1.TowerChallenge, namespace, name string, records []webappv1alpha1.MoveRecord) (client.Object, error) {
+	data, err := moveData(records[0])
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{moveFormatAnnotation: records[0].Version},
+		},
+		Data: data,
+	}, nil
+}
+
+func (s *configMapSink) Prune(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, keep func(name string) bool) error {
+	return cleanupOldConfigMaps(ctx, s.Client, s.Recorder, namespace, *tc, keep)
+}
+
+func (s *configMapSink) Adopt(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return adoptConfigMaps(ctx, s.Client, namespace, tc)
+}
+
+func (s *configMapSink) Release(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return releaseObjects(ctx, s.Client, s.ObjectKind(), &corev1.ConfigMapList{}, namespace, tc)
+}
+
+func (s *configMapSink) Stale(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) ([]int64, error) {
+	return staleObjects(ctx, s.Client, &corev1.ConfigMapList{}, namespace, tc)
+}
+
+func (s *configMapSink) Verify(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource) ([]int64, error) {
+	return verifyObjects(ctx, s.Client, &corev1.ConfigMapList{}, namespace, tc, moves, moveConfigMap)
+}
+
+// defaultMaxChunkBytes is used when a challenge does not set output.maxChunkBytes.
+const defaultMaxChunkBytes = 512 * 1024
+
+// chunkedConfigMapSink packs consecutive moves into ConfigMaps named
+// <challenge>-moves-<chunk>. Every chunk holds as many moves as fit within
+// the byte budget of the challenge when each move takes as many bytes as the
+// longest record of the solution could, so the chunk holding a move follows
+// from its index.
+type chunkedConfigMapSink struct {
+	client.Client
+	Recorder record.EventRecorder
+}
+
+func (s *chunkedConfigMapSink) ObjectKind() string { return "ConfigMap" }
+
+func (s *chunkedConfigMapSink) Layout(tc *webappv1alpha1.TowerChallenge, moves MoveSource) (string, int64, error) {
+	budget := tc.Spec.Output.MaxChunkBytes
+	if budget == 0 {
+		budget = defaultMaxChunkBytes
+	}
+	data, err := chunkData([]webappv1alpha1.MoveRecord{moves.LongestRecord()})
+	if err != nil {
+		return "", 0, err
+	}
+	size := 0
+	for key, value := range data {
+		size += len(key) + len(value)
+	}
+	if size > budget {
+		return "", 0, fmt.Errorf("a move can take %d bytes, more than the chunk budget of %d", size, budget)
+	}
+	return tc.Name + "-moves-", int64(budget / size), nil
+}
+
+func (s *chunkedConfigMapSink) Publish(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource, after int64, limit int, existing func(name string) bool) ([]webappv1alpha1.MoveChunk, error) {
+	prefix, perObject, err := s.Layout(tc, moves)
+	if err != nil {
+		return nil, err
+	}
+	return publishObjects(ctx, s.Client, tc, namespace, moves, prefix, perObject, after, limit, existing, chunkConfigMap)
+}
+
+// chunkConfigMap builds the ConfigMap holding the consecutive moves of records.
+func chunkConfigMap(tc *webappv1alpha1.TowerChallenge, namespace, name string, records []webappv1alpha1.MoveRecord) (client.Object, error) {
+	data, err := chunkData(records)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{moveFormatAnnotation: webappv1alpha1.MoveRecordVersion},
+		},
+		Data: data,
+	}, nil
+}
+
+// chunkData returns the data of a chunk holding records: every move is
+// stored under move-<index> as text and move-<index>.json as a record.
+func chunkData(records []webappv1alpha1.MoveRecord) (map[string]string, error) {
+	data := make(map[string]string, 2*len(records))
+	for _, rec := range records {
+		asJSON, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("move-%d", rec.Index)
+		data[key] = moveText(rec)
+		data[key+".json"] = string(asJSON)
+	}
+	return data, nil
+}
+
+func (s *chunkedConfigMapSink) Prune(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, keep func(name string) bool) error {
+	return cleanupOldConfigMaps(ctx, s.Client, s.Recorder, namespace, *tc, keep)
+}
+
+func (s *chunkedConfigMapSink) Adopt(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return adoptConfigMaps(ctx, s.Client, namespace, tc)
+}
+
+func (s *chunkedConfigMapSink) Release(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return releaseObjects(ctx, s.Client, s.ObjectKind(), &corev1.ConfigMapList{}, namespace, tc)
+}
+
+func (s *chunkedConfigMapSink) Stale(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) ([]int64, error) {
+	return staleObjects(ctx, s.Client, &corev1.ConfigMapList{}, namespace, tc)
+}
+
+func (s *chunkedConfigMapSink) Verify(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource) ([]int64, error) {
+	return verifyObjects(ctx, s.Client, &corev1.ConfigMapList{}, namespace, tc, moves, chunkConfigMap)
+}
+
+// secretSink writes one Secret per move, for clusters where the solution
+// must not be readable by everyone allowed to read ConfigMaps.
+type secretSink struct {
+	client.Client
+	Recorder record.EventRecorder
+}
+
+func (s *secretSink) ObjectKind() string { return "Secret" }
+
+func (s *secretSink) Layout(tc *webappv1alpha1.TowerChallenge, moves MoveSource) (string, int64, error) {
+	return tc.Name + "-move-", 1, nil
+}
+
+func (s *secretSink) Publish(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource, after int64, limit int, existing func(name string) bool) ([]webappv1alpha1.MoveChunk, error) {
+	prefix, perObject, err := s.Layout(tc, moves)
+	if err != nil {
+		return nil, err
+	}
+	return publishObjects(ctx, s.Client, tc, namespace, moves, prefix, perObject, after, limit, existing, moveSecret)
+}
+
+// moveSecret builds the Secret holding the single move of records.
+func moveSecret(tc *webappv1alpha1.TowerChallenge, namespace, name string, records []webappv1alpha1.MoveRecord) (client.Object, error) {
+	data, err := moveData(records[0])
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{moveFormatAnnotation: records[0].Version},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: make(map[string][]byte, len(data)),
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret, nil
+}
+
+func (s *secretSink) Prune(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, keep func(name string) bool) error {
+	return pruneObjects(ctx, s.Client, s.Recorder, s.ObjectKind(), &corev1.SecretList{}, namespace, tc, keep)
+}
+
+// Adopt does nothing: Secrets have been written with owner references from
+// the start, so one without a controller was not written by this operator.
+func (s *secretSink) Adopt(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return nil
+}
+
+func (s *secretSink) Release(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return releaseObjects(ctx, s.Client, s.ObjectKind(), &corev1.SecretList{}, namespace, tc)
+}
+
+func (s *secretSink) Stale(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) ([]int64, error) {
+	return staleObjects(ctx, s.Client, &corev1.SecretList{}, namespace, tc)
+}
+
+func (s *secretSink) Verify(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource) ([]int64, error) {
+	return verifyObjects(ctx, s.Client, &corev1.SecretList{}, namespace, tc, moves, moveSecret)
+}
+
+// towerMoveSink writes one TowerMove resource per move.
+type towerMoveSink struct {
+	client.Client
+	Recorder record.EventRecorder
+}
+
+func (s *towerMoveSink) ObjectKind() string { return "TowerMove" }
+
+func (s *towerMoveSink) Layout(tc *webappv1alpha1.TowerChallenge, moves MoveSource) (string, int64, error) {
+	return tc.Name + "-move-", 1, nil
+}
+
+func (s *towerMoveSink) Publish(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource, after int64, limit int, existing func(name string) bool) ([]webappv1alpha1.MoveChunk, error) {
+	prefix, perObject, err := s.Layout(tc, moves)
+	if err != nil {
+		return nil, err
+	}
+	return publishObjects(ctx, s.Client, tc, namespace, moves, prefix, perObject, after, limit, existing, towerMove)
+}
+
+// towerMove builds the TowerMove holding the single move of records.
+func towerMove(tc *webappv1alpha1.TowerChallenge, namespace, name string, records []webappv1alpha1.MoveRecord) (client.Object, error) {
+	return &webappv1alpha1.TowerMove{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: webappv1alpha1.TowerMoveSpec{
+			Challenge: tc.Name,
+			Step:      moveText(records[0]),
+			Record:    records[0],
+		},
+	}, nil
+}
+
+func (s *towerMoveSink) Prune(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, keep func(name string) bool) error {
+	return pruneObjects(ctx, s.Client, s.Recorder, s.ObjectKind(), &webappv1alpha1.TowerMoveList{}, namespace, tc, keep)
+}
+
+// Adopt does nothing: TowerMoves have been written with owner references
+// from the start, so one without a controller was not written by this operator.
+func (s *towerMoveSink) Adopt(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return nil
+}
+
+func (s *towerMoveSink) Release(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) error {
+	return releaseObjects(ctx, s.Client, s.ObjectKind(), &webappv1alpha1.TowerMoveList{}, namespace, tc)
+}
+
+func (s *towerMoveSink) Stale(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string) ([]int64, error) {
+	return staleObjects(ctx, s.Client, &webappv1alpha1.TowerMoveList{}, namespace, tc)
+}
+
+func (s *towerMoveSink) Verify(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource) ([]int64, error) {
+	return verifyObjects(ctx, s.Client, &webappv1alpha1.TowerMoveList{}, namespace, tc, moves, towerMove)
+}
+
+// pruneObjects deletes the objects of the given kind that are controlled by tc
+// and whose names keep does not report; list must be a list of that kind. The
+// deletions are reported on tc through recorder.
+func pruneObjects(ctx context.Context, c client.Client, recorder record.EventRecorder, kind string, list client.ObjectList, namespace string, tc *webappv1alpha1.TowerChallenge, keep func(name string) bool) error {
+	if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{challengeLabel: tc.Name}); err != nil {
+		return err
+	}
+	deleted := 0
+	defer func() { recordPruned(recorder, tc, kind, namespace, deleted) }()
+	return meta.EachListItem(list, func(o runtime.Object) error {
+		obj, ok := o.(client.Object)
+		if !ok || (keep != nil && keep(obj.GetName())) || !metav1.IsControlledBy(obj, tc) {
+			return nil
+		}
+		if err := c.Delete(ctx, obj); err != nil && !kerrors.IsNotFound(err) {
+			artifactFailures.WithLabelValues(kind, operationDelete).Inc()
+			recorder.Eventf(tc, corev1.EventTypeWarning, eventReasonPruneFailed, "Failed to delete %s %s/%s: %v", kind, namespace, obj.GetName(), err)
+			return err
+		}
+		deleted++
+		artifactOperations.WithLabelValues(kind, operationDeleted).Inc()
+		log.FromContext(ctx).Info("Deleted old or invalid "+kind, kind, obj.GetName())
+		return nil
+	})
+}
+
+// recordPruned reports on tc that deleted objects of kind were pruned from
+// namespace, if there were any.
+func recordPruned(recorder record.EventRecorder, tc *webappv1alpha1.TowerChallenge, kind, namespace string, deleted int) {
+	if deleted == 0 {
+		return
+	}
+	recorder.Eventf(tc, corev1.EventTypeNormal, eventReasonPruned, "Deleted %d old %s objects from namespace %s", deleted, kind, namespace)
+}
+
+// adoptConfigMaps sets tc as the controller of the ConfigMaps in namespace
+// that were written for it before owner references were set: those carrying
+// its challenge label, named like a move of tc, without a controller and not
+// released by an earlier challenge of the same name. Any other ConfigMap is
+// left alone, so that pruning never deletes objects the
+// operator did not write.
+func adoptConfigMaps(ctx context.Context, c client.Client, namespace string, tc *webappv1alpha1.TowerChallenge) error {
+	var list corev1.ConfigMapList
+	if err := c.List(ctx, &list, client.InNamespace(namespace), client.MatchingLabels{challengeLabel: tc.Name}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		cm := &list.Items[i]
+		if !legacyMoveName(tc, cm.Name) || metav1.GetControllerOf(cm) != nil || cm.Annotations[releasedAnnotation] != "" {
+			continue
+		}
+		patch := client.MergeFrom(cm.DeepCopy())
+		if err := controllerutil.SetControllerReference(tc, cm, c.Scheme()); err != nil {
+			return err
+		}
+		if err := c.Patch(ctx, cm, patch, fieldOwner); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+		log.FromContext(ctx).Info("Adopted existing ConfigMap", "ConfigMap", cm.Name)
+	}
+	return nil
+}
+
+// legacyMoveName reports whether name is moveObjectName of tc for some move.
+func legacyMoveName(tc *webappv1alpha1.TowerChallenge, name string) bool {
+	suffix, ok := strings.CutPrefix(name, tc.Name+"-move-")
+	if !ok {
+		return false
+	}
+	index, err := strconv.ParseInt(suffix, 10, 64)
+	return err == nil && index > 0 && strconv.FormatInt(index, 10) == suffix
+}
+
+// releaseObjects removes tc as the controller of the objects of the given kind
+// it controls in namespace and swaps their challengeLabel for
+// releasedAnnotation; list must be a list of that kind.
+func releaseObjects(ctx context.Context, c client.Client, kind string, list client.ObjectList, namespace string, tc *webappv1alpha1.TowerChallenge) error {
+	if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{challengeLabel: tc.Name}); err != nil {
+		return err
+	}
+	return meta.EachListItem(list, func(o runtime.Object) error {
+		obj, ok := o.(client.Object)
+		if !ok || !metav1.IsControlledBy(obj, tc) {
+			return nil
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		if err := controllerutil.RemoveControllerReference(tc, obj, c.Scheme()); err != nil {
+			return err
+		}
+		labels := obj.GetLabels()
+		delete(labels, challengeLabel)
+		obj.SetLabels(labels)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[releasedAnnotation] = tc.Name
+		obj.SetAnnotations(annotations)
+		if err := c.Patch(ctx, obj, patch, fieldOwner); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+		log.FromContext(ctx).Info("Released "+kind, kind, obj.GetName())
+		return nil
+	})
+}
+
+// controlledObjects returns the objects in list controlled by tc in
+// namespace by name; list selects the kind of object.
+func controlledObjects(ctx context.Context, c client.Client, list client.ObjectList, namespace string, tc *webappv1alpha1.TowerChallenge) (map[string]client.Object, error) {
+	if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{challengeLabel: tc.Name}); err != nil {
+		return nil, err
+	}
+	existing := make(map[string]client.Object)
+	if err := meta.EachListItem(list, func(o runtime.Object) error {
+		if obj, ok := o.(client.Object); ok && metav1.IsControlledBy(obj, tc) {
+			existing[obj.GetName()] = obj
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// staleObjects returns the numbers of the objects listed in the status of tc
+// that no object in list controlled by tc has, or whose object fails
+// checkDigests; list selects the kind of object.
+func staleObjects(ctx context.Context, c client.Client, list client.ObjectList, namespace string, tc *webappv1alpha1.TowerChallenge) ([]int64, error) {
+	existing, err := controlledObjects(ctx, c, list, namespace, tc)
+	if err != nil {
+		return nil, err
+	}
+	var stale []int64
+	for i := int64(1); i <= tc.Status.ArtifactCount; i++ {
+		obj, ok := existing[artifactName(tc, i)]
+		if !ok {
+			stale = append(stale, i)
+			continue
+		}
+		intact, err := checkDigests(obj, tc)
+		if err != nil {
+			return nil, err
+		}
+		if !intact {
+			stale = append(stale, i)
+		}
+	}
+	return stale, nil
+}
+
+// verifyObjects returns the numbers of the objects listed in the status of
+// tc that no object in list controlled by tc has, or whose object does not
+// hold what build makes of its part of moves; list selects the kind of object.
+// The expected objects are built one at a time, in order, so that the
+// records are never all held at once.
+func verifyObjects(ctx context.Context, c client.Client, list client.ObjectList, namespace string, tc *webappv1alpha1.TowerChallenge, moves MoveSource, build objectBuilder) ([]int64, error) {
+	existing, err := controlledObjects(ctx, c, list, namespace, tc)
+	if err != nil {
+		return nil, err
+	}
+	var stale []int64
+	perObject, total := tc.Status.MovesPerArtifact, moves.Total()
+	for i := int64(1); i <= tc.Status.ArtifactCount; i++ {
+		name := artifactName(tc, i)
+		obj, ok := existing[name]
+		if !ok {
+			stale = append(stale, i)
+			continue
+		}
+		first := (i-1)*perObject + 1
+		records, err := moves.Records(first, min(first+perObject-1, total))
+		if err != nil {
+			return nil, err
+		}
+		expected, err := build(tc, namespace, name, records)
+		if err != nil {
+			return nil, err
+		}
+		verified, err := verifyDigests(obj, expected, tc)
+		if err != nil {
+			return nil, err
+		}
+		if !verified {
+			stale = append(stale, i)
+		}
+	}
+	return stale, nil
+}
+
+// eachObjectKind calls fn once for every kind of object written by sinks,
+// in a stable order, with the first sink writing that kind.
+func eachObjectKind(sinks map[webappv1alpha1.OutputKind]MoveSink, fn func(webappv1alpha1.OutputKind, MoveSink) error) error {
+	kinds := make([]webappv1alpha1.OutputKind, 0, len(sinks))
+	for kind := range sinks {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	seen := make(map[string]bool)
+	for _, kind := range kinds {
+		sink := sinks[kind]
+		if seen[sink.ObjectKind()] {
+			continue
+		}
+		seen[sink.ObjectKind()] = true
+		if err := fn(kind, sink); err != nil {
+			return err
+		}
+	}
+	return nil
+}
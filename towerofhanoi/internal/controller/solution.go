@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/solver"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// solution is a solved challenge: the discs on each peg before the first
+// move, listed from the bottom up, and the moves that reach the goal.
+type solution struct {
+	discs int
+	start [][]int
+	// moves holds every move, except for the classic tower, whose moves
+	// are computed from their index instead. total counts them either way.
+	moves []solver.Move
+	total uint64
+	// optimal is the length of an optimal solution under the rules of the variant.
+	optimal uint64
+	// splits is only set for Frame–Stewart solutions.
+	splits []solver.Split
+	// classic is set when the moves are the textbook solution for a full
+	// tower, whose positions can be computed directly from a move index.
+	classic bool
+}
+
+// solveChallenge picks the solver for the variant, peg count and states of
+// spec. start and goal hold the peg of every disc, as from resolveStates.
+// The classic tower is not solved at all: its moves are worked out when
+// they are needed.
+func solveChallenge(spec webappv1alpha1.TowerChallengeSpec, b board, start, goal []solver.Peg) (solution, error) {
+	n := spec.Discs
+	order := [3]solver.Peg{0, 1, 2}
+	sol := solution{discs: n, start: b.stacks(start)}
+
+	switch {
+	case spec.Variant == webappv1alpha1.VariantCyclic:
+		sol.moves = solver.SolveCyclic(n, order, b.source, b.target)
+	case spec.Variant == webappv1alpha1.VariantAdjacent:
+		sol.moves = solver.SolveAdjacent(n, order, b.source, b.target)
+	case spec.Variant == webappv1alpha1.VariantBicolor:
+		sol.moves = solver.SolveBicolor(n, b.source, b.target, b.spares[0])
+		// Both discs of every size start on the source peg.
+		sol.start[b.source] = doubleStack(sol.start[b.source])
+	case len(spec.InitialState) > 0 || len(spec.TargetState) > 0:
+		moves, err := solver.SolveBetween(start, goal)
+		if err != nil {
+			return solution{}, err
+		}
+		sol.moves = moves
+		sol.total = uint64(len(moves))
+		sol.optimal = sol.total
+		return sol, nil
+	case len(b.spares) > 1:
+		sol.moves, sol.splits = solver.FrameStewart(n, b.source, b.target, b.spares...)
+	default:
+		sol.classic = true
+	}
+	sol.total = uint64(len(sol.moves))
+	if sol.classic {
+		sol.total = solver.MoveCount(n)
+	}
+	var err error
+	sol.optimal, err = optimalMoves(spec, b, start, goal)
+	return sol, err
+}
+
+// move returns move k of sol, counting from 1.
+func (sol solution) move(b board, k uint64) (solver.Move, error) {
+	if k < 1 || k > sol.total {
+		return solver.Move{}, fmt.Errorf("move %d is not between 1 and %d", k, sol.total)
+	}
+	if sol.classic {
+		return solver.MoveAt(sol.discs, k, b.source, b.target, b.spares[0])
+	}
+	return sol.moves[k-1], nil
+}
+
+// stacksAfter returns the discs on each peg once the first k moves of sol
+// have been played.
+func (sol solution) stacksAfter(b board, k uint64) ([][]int, error) {
+	if sol.classic {
+		state, err := solver.StateAfter(sol.discs, k, b.source, b.target, b.spares[0])
+		if err != nil {
+			return nil, err
+		}
+		return b.stacks(state), nil
+	}
+	stacks := make([][]int, len(sol.start))
+	for i, stack := range sol.start {
+		stacks[i] = append([]int(nil), stack...)
+	}
+	for _, m := range sol.moves[:k] {
+		play(stacks, m)
+	}
+	return stacks, nil
+}
+
+// play moves the top disc of stacks[m.From] onto stacks[m.To].
+func play(stacks [][]int, m solver.Move) {
+	from := stacks[m.From]
+	stacks[m.From] = from[:len(from)-1]
+	stacks[m.To] = append(stacks[m.To], from[len(from)-1])
+}
+
+// classicTower reports whether spec asks for the textbook solution for a full
+// tower on three pegs, whose moves solveChallenge marks as classic.
+func classicTower(spec webappv1alpha1.TowerChallengeSpec, b board) bool {
+	return (spec.Variant == "" || spec.Variant == webappv1alpha1.VariantClassic) &&
+		len(spec.InitialState) == 0 && len(spec.TargetState) == 0 && len(b.spares) == 1
+}
+
+// optimalMoves returns the length of an optimal solution of spec under the
+// rules of its variant, without generating the moves.
+func optimalMoves(spec webappv1alpha1.TowerChallengeSpec, b board, start, goal []solver.Peg) (uint64, error) {
+	n := spec.Discs
+	order := [3]solver.Peg{0, 1, 2}
+	switch {
+	case spec.Variant == webappv1alpha1.VariantCyclic:
+		return solver.CyclicCount(n, (b.source+1)%3 == b.target), nil
+	case spec.Variant == webappv1alpha1.VariantAdjacent:
+		return solver.AdjacentCount(n, b.source != order[1] && b.target != order[1]), nil
+	case spec.Variant == webappv1alpha1.VariantBicolor:
+		return solver.BicolorCount(n), nil
+	case len(spec.InitialState) > 0 || len(spec.TargetState) > 0:
+		return solver.CountBetween(start, goal)
+	case len(b.spares) > 1:
+		return solver.FrameStewartCount(n, len(b.names)), nil
+	default:
+		return solver.MoveCount(n), nil
+	}
+}
+
+// doubleStack puts a second disc of the same size on each disc of stack.
+func doubleStack(stack []int) []int {
+	doubled := make([]int, 0, 2*len(stack))
+	for _, disc := range stack {
+		doubled = append(doubled, disc, disc)
+	}
+	return doubled
+}
+
+// inspectMove computes move k and the resulting peg configuration directly
+// from the move index, without generating the moves before it.
+func inspectMove(b board, discs int, k int64) (*webappv1alpha1.MoveSnapshot, error) {
+	move, err := solver.MoveAt(discs, uint64(k), b.source, b.target, b.spares[0])
+	if err != nil {
+		return nil, err
+	}
+	state, err := solver.StateAfter(discs, uint64(k), b.source, b.target, b.spares[0])
+	if err != nil {
+		return nil, err
+	}
+	return b.snapshot(k, move, b.stacks(state)), nil
+}
+
+// solutionCache holds the moves of the challenges being published between
+// reconciles. Each entry remembers the spec hash it was solved for and where
+// the last batch stopped, so the next batch goes on from there.
+type solutionCache struct {
+	mu      sync.Mutex
+	entries map[types.UID]cachedSolution
+}
+
+type cachedSolution struct {
+	hash  string
+	moves *moveSource
+}
+
+// get returns the moves solved for the challenge uid when its spec hash was
+// hash, or nil.
+func (c *solutionCache) get(uid types.UID, hash string) *moveSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[uid]; ok && entry.hash == hash {
+		return entry.moves
+	}
+	return nil
+}
+
+// put records the moves solved for the challenge uid with spec hash hash,
+// replacing those solved for an earlier spec.
+func (c *solutionCache) put(uid types.UID, hash string, moves *moveSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[types.UID]cachedSolution)
+	}
+	c.entries[uid] = cachedSolution{hash: hash, moves: moves}
+}
+
+// forget drops the moves of the challenge uid once they are no longer needed.
+func (c *solutionCache) forget(uid types.UID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, uid)
+}
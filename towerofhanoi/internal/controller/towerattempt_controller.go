@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/simulator"
+	"hanoi.com/towerofhanoi/pkg/solver"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// attemptChallengeField indexes TowerAttempts by the challenge they solve.
+const attemptChallengeField = "spec.challenge"
+
+// Reasons of the events recorded on a TowerAttempt.
+const (
+	eventReasonGraded      = "Graded"
+	eventReasonIllegalMove = "IllegalMove"
+)
+
+// TowerAttemptReconciler grades the solutions submitted as TowerAttempts by
+// replaying them on a simulator of the board of their challenge.
+type TowerAttemptReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder records events on the attempts being graded.
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=webapp.hanoi.com,resources=towerattempts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=webapp.hanoi.com,resources=towerattempts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=webapp.hanoi.com,resources=towerchallenges,verbs=get;list;watch
+
+func (r *TowerAttemptReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var attempt webappv1alpha1.TowerAttempt
+	if err := r.Get(ctx, req.NamespacedName, &attempt); err != nil {
+		if !kerrors.IsNotFound(err) {
+			log.Error(err, "Unable to fetch TowerAttempt")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Attempts are graded again whenever their challenge changes, so there is
+	// nothing to retry while it is missing or invalid.
+	var challenge webappv1alpha1.TowerChallenge
+	if err := r.Get(ctx, client.ObjectKey{Name: attempt.Spec.Challenge}, &challenge); err != nil {
+		if !kerrors.IsNotFound(err) {
+			log.Error(err, "Unable to fetch TowerChallenge", "challenge", attempt.Spec.Challenge)
+			attempt.Status.SetConditions(xpv1.ReconcileError(err))
+			if statusErr := r.applyStatus(ctx, &attempt); statusErr != nil {
+				log.Error(statusErr, "Failed to update TowerAttempt status")
+			}
+			return ctrl.Result{}, err
+		}
+		err = fmt.Errorf("TowerChallenge %q does not exist", attempt.Spec.Challenge)
+		attempt.Status.SetConditions(xpv1.Unavailable().WithMessage(err.Error()), webappv1alpha1.ChallengeNotFound(err))
+		return ctrl.Result{}, r.applyStatus(ctx, &attempt)
+	}
+
+	previous := attempt.Status.DeepCopy()
+	if err := gradeAttempt(challenge.Spec, attempt.Spec.Moves, &attempt.Status); errors.Is(err, errBelowOptimal) {
+		// The grader or the optimum is wrong, not the spec, so the grade
+		// already recorded is kept and the attempt is graded again later.
+		log.Error(err, "Failed to grade TowerAttempt")
+		attempt.Status = *previous
+		attempt.Status.SetConditions(xpv1.ReconcileError(err))
+		if statusErr := r.applyStatus(ctx, &attempt); statusErr != nil {
+			log.Error(statusErr, "Failed to update TowerAttempt status")
+		}
+		return ctrl.Result{}, err
+	} else if err != nil {
+		err = fmt.Errorf("TowerChallenge %q cannot be played: %w", challenge.Name, err)
+		attempt.Status.SetConditions(xpv1.Unavailable().WithMessage(err.Error()), webappv1alpha1.ValidationFailed(err))
+		return ctrl.Result{}, r.applyStatus(ctx, &attempt)
+	}
+	status := &attempt.Status
+	status.ObservedGeneration = attempt.Generation
+	status.SetConditions(xpv1.Available(), xpv1.ReconcileSuccess())
+	if err := r.applyStatus(ctx, &attempt); err != nil {
+		log.Error(err, "Failed to update TowerAttempt status")
+		return ctrl.Result{}, err
+	}
+
+	if previous.ObservedGeneration != status.ObservedGeneration || previous.Verdict != status.Verdict {
+		if illegal := status.FirstIllegalMove; illegal != nil {
+			r.Recorder.Eventf(&attempt, corev1.EventTypeWarning, eventReasonIllegalMove,
+				"Move %d from %s to %s is illegal: %s", illegal.Index, illegal.Move.From, illegal.Move.To, illegal.Reason)
+		} else {
+			r.Recorder.Eventf(&attempt, corev1.EventTypeNormal, eventReasonGraded,
+				"%s with %d moves, the optimum is %d", status.Verdict, status.Moves, status.OptimalMoves)
+		}
+	}
+	log.Info("Graded TowerAttempt", "verdict", status.Verdict, "moves", status.Moves)
+	return ctrl.Result{}, nil
+}
+
+// errBelowOptimal is returned by gradeAttempt for an attempt that reaches the
+// goal in fewer moves than the optimum, which only a bug can bring about.
+var errBelowOptimal = errors.New("solved in fewer moves than the optimum")
+
+// gradeAttempt replays moves from the initial state of spec under the rules
+// of its variant and records the outcome in status. The colours of the discs
+// of a bicolor tower are followed as well, as it is only solved once every
+// pair is back in its original order. It fails when spec itself cannot be
+// played, and with errBelowOptimal when the grade cannot be right.
+func gradeAttempt(spec webappv1alpha1.TowerChallengeSpec, moves []webappv1alpha1.AttemptMove, status *webappv1alpha1.TowerAttemptStatus) error {
+	// Grading only counts the moves of the solution, so it is not held to
+	// the ceilings of the challenges the controller solves.
+	if err := validateTowerChallenge(webappv1alpha1.TowerChallenge{Spec: spec}, solver.MaxDiscs-1, math.MaxUint64, math.MaxUint64); err != nil {
+		return err
+	}
+	b, err := newBoard(spec)
+	if err != nil {
+		return err
+	}
+	start, goal, err := b.resolveStates(spec)
+	if err != nil {
+		return err
+	}
+	optimal, err := optimalMoves(spec, b, start, goal)
+	if err != nil {
+		return err
+	}
+	rules, err := simulator.RulesFor(spec.Variant)
+	if err != nil {
+		return err
+	}
+	initial, want := b.stacks(start), b.stacks(goal)
+	if spec.Variant == webappv1alpha1.VariantBicolor {
+		initial[b.source] = doubleStack(initial[b.source])
+		want[b.target] = doubleStack(want[b.target])
+	}
+	sim, err := simulator.FromPegStates(b.names, b.pegStates(initial), rules)
+	if err != nil {
+		return err
+	}
+	var colours pairColours
+	if spec.Variant == webappv1alpha1.VariantBicolor {
+		colours = newPairColours(initial)
+	}
+
+	status.Moves = int64(len(moves))
+	status.OptimalMoves = int64(optimal)
+	status.Legal = true
+	status.FirstIllegalMove = nil
+	for i, m := range moves {
+		err := sim.PlayRecord(webappv1alpha1.MoveRecord{MoveSnapshot: webappv1alpha1.MoveSnapshot{
+			Index: int64(i + 1),
+			Disc:  m.Disc,
+			From:  m.From,
+			To:    m.To,
+		}})
+		var illegal *simulator.IllegalMoveError
+		if errors.As(err, &illegal) {
+			status.Legal = false
+			status.FirstIllegalMove = &webappv1alpha1.IllegalMove{Index: illegal.Index, Move: m, Reason: illegal.Err.Error()}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		colours.play(b.index(m.From), b.index(m.To))
+	}
+	status.FinalState = sim.PegStates()
+	status.Solved = status.Legal && sim.Matches(want) && colours.matches(newPairColours(want))
+
+	status.ExtraMoves = 0
+	switch {
+	case !status.Legal:
+		status.Verdict = webappv1alpha1.VerdictIllegal
+	case !status.Solved:
+		status.Verdict = webappv1alpha1.VerdictUnsolved
+	case status.Moves < status.OptimalMoves:
+		return fmt.Errorf("%d moves against an optimum of %d: %w", status.Moves, status.OptimalMoves, errBelowOptimal)
+	case status.Moves > status.OptimalMoves:
+		status.Verdict = webappv1alpha1.VerdictSolved
+		status.ExtraMoves = status.Moves - status.OptimalMoves
+	default:
+		status.Verdict = webappv1alpha1.VerdictOptimal
+	}
+	return nil
+}
+
+// pairColours follows the colours of the discs of a bicolor tower, which the
+// simulator does not track. Each peg lists, from the bottom up, whether each
+// of its discs is the light one of its size. A nil pairColours follows
+// nothing and matches anything.
+type pairColours [][]bool
+
+// newPairColours colours the discs of stacks, where each pair of discs of the
+// same size has its dark disc below the light one, as doubleStack lays them out.
+func newPairColours(stacks [][]int) pairColours {
+	colours := make(pairColours, len(stacks))
+	for i, stack := range stacks {
+		for j, disc := range stack {
+			light := j > 0 && stack[j-1] == disc && !colours[i][j-1]
+			colours[i] = append(colours[i], light)
+		}
+	}
+	return colours
+}
+
+// play moves the colour of the top disc of from onto to, following a move
+// the simulator accepted.
+func (c pairColours) play(from, to solver.Peg) {
+	if c == nil {
+		return
+	}
+	top := c[from][len(c[from])-1]
+	c[from] = c[from][:len(c[from])-1]
+	c[to] = append(c[to], top)
+}
+
+// matches reports whether every disc of c has the colour it has in want.
+func (c pairColours) matches(want pairColours) bool {
+	if c == nil {
+		return true
+	}
+	if len(c) != len(want) {
+		return false
+	}
+	for i := range c {
+		if len(c[i]) != len(want[i]) {
+			return false
+		}
+		for j := range c[i] {
+			if c[i][j] != want[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// applyStatus writes the status of attempt with server-side apply under
+// fieldManager, then records the new resource version on attempt.
+func (r *TowerAttemptReconciler) applyStatus(ctx context.Context, attempt *webappv1alpha1.TowerAttempt) error {
+	applied := &webappv1alpha1.TowerAttempt{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: webappv1alpha1.GroupVersion.String(),
+			Kind:       "TowerAttempt",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: attempt.Name, Namespace: attempt.Namespace},
+		Status:     attempt.Status,
+	}
+	if err := r.Status().Patch(ctx, applied, client.Apply, fieldOwner, client.ForceOwnership); err != nil {
+		return err
+	}
+	attempt.ResourceVersion = applied.ResourceVersion
+	return nil
+}
+
+// attemptChallenge is the index function of attemptChallengeField.
+func attemptChallenge(obj client.Object) []string {
+	attempt, ok := obj.(*webappv1alpha1.TowerAttempt)
+	if !ok {
+		return nil
+	}
+	return []string{attempt.Spec.Challenge}
+}
+
+// attemptsFor returns a request for every attempt at challenge.
+func (r *TowerAttemptReconciler) attemptsFor(ctx context.Context, challenge client.Object) []reconcile.Request {
+	var attempts webappv1alpha1.TowerAttemptList
+	if err := r.List(ctx, &attempts, client.MatchingFields{attemptChallengeField: challenge.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list TowerAttempts", "challenge", challenge.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, len(attempts.Items))
+	for i, attempt := range attempts.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: attempt.Name, Namespace: attempt.Namespace}}
+	}
+	return requests
+}
+
+func (r *TowerAttemptReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &webappv1alpha1.TowerAttempt{}, attemptChallengeField, attemptChallenge); err != nil {
+		return err
+	}
+	// Status updates of a challenge do not change how attempts are graded.
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&webappv1alpha1.TowerAttempt{}).
+		Watches(&webappv1alpha1.TowerChallenge{},
+			handler.EnqueueRequestsFromMapFunc(r.attemptsFor),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/simulator"
+	"hanoi.com/towerofhanoi/pkg/solver"
+)
+
+var _ = Describe("TowerAttempt grading", func() {
+	const namespace = "trainees"
+	var (
+		ctx      context.Context
+		c        client.Client
+		recorder *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		challenges := []client.Object{
+			&webappv1alpha1.TowerChallenge{
+				ObjectMeta: metav1.ObjectMeta{Name: "classic"},
+				Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 3},
+			},
+			&webappv1alpha1.TowerChallenge{
+				ObjectMeta: metav1.ObjectMeta{Name: "cyclic"},
+				Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2, Variant: webappv1alpha1.VariantCyclic},
+			},
+			&webappv1alpha1.TowerChallenge{
+				ObjectMeta: metav1.ObjectMeta{Name: "bicolor"},
+				Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2, Variant: webappv1alpha1.VariantBicolor},
+			},
+		}
+		c = newFakeClient(challenges...)
+		recorder = record.NewFakeRecorder(100)
+	})
+
+	// moves names the pegs of solver moves on a three-peg board.
+	moves := func(ms ...solver.Move) []webappv1alpha1.AttemptMove {
+		names := defaultPegNames(3)
+		attempt := make([]webappv1alpha1.AttemptMove, len(ms))
+		for i, m := range ms {
+			attempt[i] = webappv1alpha1.AttemptMove{Disc: m.Disk, From: names[m.From], To: names[m.To]}
+		}
+		return attempt
+	}
+
+	grade := func(challenge string, submitted []webappv1alpha1.AttemptMove) *webappv1alpha1.TowerAttempt {
+		attempt := &webappv1alpha1.TowerAttempt{
+			ObjectMeta: metav1.ObjectMeta{Name: "attempt", Namespace: namespace, Generation: 1},
+			Spec:       webappv1alpha1.TowerAttemptSpec{Challenge: challenge, Moves: submitted},
+		}
+		Expect(c.Create(ctx, attempt)).To(Succeed())
+		r := &TowerAttemptReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(attempt)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(attempt), attempt)).To(Succeed())
+		return attempt
+	}
+
+	It("grades an optimal solution", func() {
+		attempt := grade("classic", moves(solver.Solve(3, 0, 2, 1)...))
+		Expect(attempt.Status.Verdict).To(Equal(webappv1alpha1.VerdictOptimal))
+		Expect(attempt.Status.Legal).To(BeTrue())
+		Expect(attempt.Status.Solved).To(BeTrue())
+		Expect(attempt.Status.Moves).To(Equal(int64(7)))
+		Expect(attempt.Status.OptimalMoves).To(Equal(int64(7)))
+		Expect(attempt.Status.ExtraMoves).To(BeZero())
+		Expect(attempt.Status.ObservedGeneration).To(Equal(int64(1)))
+		Expect(attempt.GetCondition(xpv1.TypeReady).Reason).To(Equal(xpv1.ReasonAvailable))
+		Expect(recorder.Events).To(Receive(Equal("Normal Graded Optimal with 7 moves, the optimum is 7")))
+	})
+
+	It("counts the moves beyond the optimum", func() {
+		detour := []solver.Move{{Disk: 1, From: 0, To: 1}, {Disk: 1, From: 1, To: 0}}
+		attempt := grade("classic", moves(append(detour, solver.Solve(3, 0, 2, 1)...)...))
+		Expect(attempt.Status.Verdict).To(Equal(webappv1alpha1.VerdictSolved))
+		Expect(attempt.Status.ExtraMoves).To(Equal(int64(2)))
+	})
+
+	It("reports legal moves that stop short of the goal", func() {
+		attempt := grade("classic", moves(solver.Solve(3, 0, 2, 1)[:4]...))
+		Expect(attempt.Status.Verdict).To(Equal(webappv1alpha1.VerdictUnsolved))
+		Expect(attempt.Status.Legal).To(BeTrue())
+		Expect(attempt.Status.Solved).To(BeFalse())
+		Expect(attempt.Status.FinalState).To(Equal([]webappv1alpha1.PegState{
+			{Name: "A"}, {Name: "B", Discs: []int{2, 1}}, {Name: "C", Discs: []int{3}},
+		}))
+	})
+
+	It("stops at the first illegal move", func() {
+		attempt := grade("classic", moves(
+			solver.Move{Disk: 1, From: 0, To: 2},
+			solver.Move{Disk: 2, From: 0, To: 2},
+			solver.Move{Disk: 1, From: 1, To: 0},
+		))
+		Expect(attempt.Status.Verdict).To(Equal(webappv1alpha1.VerdictIllegal))
+		Expect(attempt.Status.Legal).To(BeFalse())
+		Expect(attempt.Status.FirstIllegalMove).To(Equal(&webappv1alpha1.IllegalMove{
+			Index:  2,
+			Move:   webappv1alpha1.AttemptMove{Disc: 2, From: "A", To: "C"},
+			Reason: "disc 2 on disc 1: a disc cannot rest on a smaller disc",
+		}))
+		Expect(attempt.Status.FinalState).To(Equal([]webappv1alpha1.PegState{
+			{Name: "A", Discs: []int{3, 2}}, {Name: "B"}, {Name: "C", Discs: []int{1}},
+		}))
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning IllegalMove Move 2 from A to C is illegal")))
+	})
+
+	It("applies the rules of the variant", func() {
+		attempt := grade("cyclic", moves(solver.Move{Disk: 1, From: 0, To: 2}))
+		Expect(attempt.Status.Verdict).To(Equal(webappv1alpha1.VerdictIllegal))
+		Expect(attempt.Status.FirstIllegalMove.Reason).To(Equal(simulator.ErrNotAllowed.Error()))
+	})
+
+	It("follows the colours of the discs of a bicolor tower", func() {
+		attempt := grade("bicolor", moves(solver.SolveBicolor(2, 0, 2, 1)...))
+		Expect(attempt.Status.Verdict).To(Equal(webappv1alpha1.VerdictOptimal))
+		Expect(attempt.Status.Moves).To(Equal(int64(11)))
+	})
+
+	It("does not count a bicolor tower with pairs in the wrong order as solved", func() {
+		// Moving every pair as a unit stacks the right sizes on the target in
+		// fewer moves, but with the colours of every pair swapped.
+		var doubled []solver.Move
+		for _, m := range solver.Solve(2, 0, 2, 1) {
+			doubled = append(doubled, m, m)
+		}
+		attempt := grade("bicolor", moves(doubled...))
+		Expect(attempt.Status.Legal).To(BeTrue())
+		Expect(attempt.Status.Solved).To(BeFalse())
+		Expect(attempt.Status.Verdict).To(Equal(webappv1alpha1.VerdictUnsolved))
+		Expect(attempt.Status.FinalState).To(Equal([]webappv1alpha1.PegState{
+			{Name: "A"}, {Name: "B"}, {Name: "C", Discs: []int{2, 2, 1, 1}},
+		}))
+	})
+
+	It("waits for a challenge that does not exist", func() {
+		attempt := grade("missing", nil)
+		Expect(attempt.Status.Verdict).To(BeEmpty())
+		synced := attempt.GetCondition(xpv1.TypeSynced)
+		Expect(synced.Status).To(Equal(corev1.ConditionFalse))
+		Expect(synced.Reason).To(Equal(webappv1alpha1.ReasonChallengeNotFound))
+	})
+
+	It("grades attempts again when their challenge changes", func() {
+		for _, name := range []string{"first", "second"} {
+			Expect(c.Create(ctx, &webappv1alpha1.TowerAttempt{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Spec:       webappv1alpha1.TowerAttemptSpec{Challenge: "classic"},
+			})).To(Succeed())
+		}
+		Expect(c.Create(ctx, &webappv1alpha1.TowerAttempt{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: namespace},
+			Spec:       webappv1alpha1.TowerAttemptSpec{Challenge: "cyclic"},
+		})).To(Succeed())
+
+		r := &TowerAttemptReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+		challenge := &webappv1alpha1.TowerChallenge{ObjectMeta: metav1.ObjectMeta{Name: "classic"}}
+		Expect(r.attemptsFor(ctx, challenge)).To(ConsistOf(
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: "first", Namespace: namespace}},
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: "second", Namespace: namespace}},
+		))
+	})
+})
@@ -2,174 +2,835 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/solver"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// challengeFinalizer holds a TowerChallenge until its deletion policy has
+// been applied to the objects holding its moves.
+const challengeFinalizer = "webapp.hanoi.com/cleanup"
+
+// defaultTargetNamespace receives the moves of challenges that do not set
+// spec.targetNamespace.
+const defaultTargetNamespace = "default"
+
+// Reasons of the events recorded on a TowerChallenge, besides the reasons of
+// the conditions it fails with.
+const (
+	eventReasonSolved      = "Solved"
+	eventReasonWriteFailed = "WriteFailed"
+	eventReasonPruned      = "Pruned"
+	eventReasonPruneFailed = "PruneFailed"
+	eventReasonStale       = "StaleMoves"
+)
+
 type TowerChallengeReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Recorder records events on the challenges being reconciled.
+	Recorder record.EventRecorder
+
+	// Sinks overrides the sink used for an output kind; kinds that are not
+	// listed use the built-in sinks.
+	Sinks map[webappv1alpha1.OutputKind]MoveSink
+
+	// PublishBatchSize bounds the number of objects written per reconcile.
+	// Zero means defaultPublishBatchSize.
+	PublishBatchSize int
+
+	// StepsPreview is the number of moves kept at each end of status.steps.
+	// Zero means defaultStepsPreview.
+	StepsPreview int
+
+	// MaxDiscs is the largest disc count the controller solves, whether or
+	// not the admission webhook is running. Zero means
+	// webappv1alpha1.DefaultMaxDiscs.
+	MaxDiscs int
+
+	// MaxMoves is the largest number of moves a challenge may take to solve,
+	// as counted by webappv1alpha1.MaxMoveCount. The variant solvers hold
+	// every move in memory, so this bounds what a solve allocates. Zero means
+	// webappv1alpha1.DefaultMaxMoves.
+	MaxMoves uint64
+
+	// MaxObjects is the largest number of objects the moves of a challenge
+	// may be written to. Zero means webappv1alpha1.DefaultMaxObjects.
+	MaxObjects uint64
+
+	// VerifyPeriod is how often the moves of a completed challenge are
+	// compared with its solution, which is solved again for it. Every other
+	// reconcile only checks each object against the digest it carries.
+	// Zero means defaultVerifyPeriod.
+	VerifyPeriod time.Duration
+
+	// solutions keeps the solution of every challenge whose moves are being
+	// published, so that each batch does not solve it again.
+	solutions solutionCache
 }
 
+// missingNamespaceRetry is how long a challenge whose target namespace does
+// not exist waits before it is checked again.
+const missingNamespaceRetry = time.Minute
+
+// publishRequeueDelay is how long a challenge waits between two batches of moves.
+const publishRequeueDelay = 100 * time.Millisecond
+
+// defaultPublishBatchSize is used when PublishBatchSize is not set.
+const defaultPublishBatchSize = 500
+
+// staleNamesReported bounds the names of stale objects listed in an event.
+const staleNamesReported = 10
+
+// defaultStepsPreview is used when StepsPreview is not set.
+const defaultStepsPreview = 10
+
+// defaultVerifyPeriod is used when VerifyPeriod is not set.
+const defaultVerifyPeriod = 6 * time.Hour
+
+func (r *TowerChallengeReconciler) stepsPreview() int {
+	if r.StepsPreview <= 0 {
+		return defaultStepsPreview
+	}
+	return r.StepsPreview
+}
+
+func (r *TowerChallengeReconciler) maxDiscs() int {
+	if r.MaxDiscs <= 0 {
+		return webappv1alpha1.DefaultMaxDiscs
+	}
+	return r.MaxDiscs
+}
+
+func (r *TowerChallengeReconciler) maxMoves() uint64 {
+	if r.MaxMoves == 0 {
+		return webappv1alpha1.DefaultMaxMoves
+	}
+	return r.MaxMoves
+}
+
+func (r *TowerChallengeReconciler) maxObjects() uint64 {
+	if r.MaxObjects == 0 {
+		return webappv1alpha1.DefaultMaxObjects
+	}
+	return r.MaxObjects
+}
+
+func (r *TowerChallengeReconciler) verifyPeriod() time.Duration {
+	if r.VerifyPeriod <= 0 {
+		return defaultVerifyPeriod
+	}
+	return r.VerifyPeriod
+}
+
+func (r *TowerChallengeReconciler) publishBatchSize() int {
+	if r.PublishBatchSize <= 0 {
+		return defaultPublishBatchSize
+	}
+	return r.PublishBatchSize
+}
+
+// moveSinks returns the sink for every output kind.
+func (r *TowerChallengeReconciler) moveSinks() map[webappv1alpha1.OutputKind]MoveSink {
+	sinks := newMoveSinks(r.Client, r.Recorder)
+	for kind, sink := range r.Sinks {
+		sinks[kind] = sink
+	}
+	return sinks
+}
+
+//+kubebuilder:rbac:groups=webapp.hanoi.com,resources=towerchallenges,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=webapp.hanoi.com,resources=towerchallenges/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=webapp.hanoi.com,resources=towerchallenges/finalizers,verbs=update
+//+kubebuilder:rbac:groups=webapp.hanoi.com,resources=towermoves,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
 func (r *TowerChallengeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
 	var towerChallenge webappv1alpha1.TowerChallenge
 	if err := r.Get(ctx, req.NamespacedName, &towerChallenge); err != nil {
-		log.Error(err, "Unable to fetch TowerChallenge")
+		if !kerrors.IsNotFound(err) {
+			log.Error(err, "Unable to fetch TowerChallenge")
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	defer func(start time.Time) {
+		reconcileDuration.WithLabelValues(discsLabel(towerChallenge.Spec.Discs)).Observe(time.Since(start).Seconds())
+	}(time.Now())
+
+	if !towerChallenge.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &towerChallenge)
+	}
+	if controllerutil.AddFinalizer(&towerChallenge, challengeFinalizer) {
+		if err := r.Update(ctx, &towerChallenge, fieldOwner); err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	hash, err := specHash(towerChallenge.Spec)
+	if err != nil {
+		return r.markError(ctx, &towerChallenge, err)
+	}
+	verified := towerChallenge.Status.VerifiedTime
+	if upToDate, err := r.upToDate(ctx, &towerChallenge, hash); err != nil {
+		return r.markError(ctx, &towerChallenge, err)
+	} else if upToDate {
+		outstandingMoves.WithLabelValues(towerChallenge.Name).Set(0)
+		log.V(1).Info("TowerChallenge is unchanged, skipping")
+		// Edits the hash leaves out, such as the deletion policy, are only
+		// acknowledged, as is a verification of the moves.
+		if towerChallenge.Status.ObservedGeneration != towerChallenge.Generation ||
+			!towerChallenge.Status.VerifiedTime.Equal(&verified) {
+			towerChallenge.Status.ObservedGeneration = towerChallenge.Generation
+			if err := r.applyStatus(ctx, &towerChallenge); err != nil {
+				log.Error(err, "Failed to update TowerChallenge status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: r.nextVerification(&towerChallenge)}, nil
+	}
+
 	startTime := time.Now()
 	if towerChallenge.Status.StartTime.IsZero() {
 		towerChallenge.Status.StartTime = metav1.Time{Time: startTime}
 	}
 
-	if err := validateTowerChallenge(towerChallenge); err != nil {
-		towerChallenge.Status.Phase = "Failed"
-		towerChallenge.Status.ErrorMessage = err.Error()
-		_ = r.Status().Update(ctx, &towerChallenge)
-		return ctrl.Result{}, err
+	if err := validateTowerChallenge(towerChallenge, r.maxDiscs(), r.maxMoves(), r.maxObjects()); err != nil {
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
+	}
+	b, err := newBoard(towerChallenge.Spec)
+	if err != nil {
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
 	}
 
-	steps := solveHanoi(towerChallenge.Spec.Discs, "A", "C", "B")
-	configMapNames := manageConfigMaps(ctx, r, req.Namespace, towerChallenge, steps)
-	validNames := make(map[string]bool)
-	for _, name := range configMapNames {
-		validNames[name] = true
+	start, goal, err := b.resolveStates(towerChallenge.Spec)
+	if err != nil {
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.InvalidState(err))
+	}
+	// The length of the solution and the inspected move are worked out
+	// before solving, so a mistaken inspectMove fails without a solve.
+	optimal, err := optimalMoves(towerChallenge.Spec, b, start, goal)
+	if err != nil {
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.InvalidState(err))
+	}
+	towerChallenge.Status.TotalMoves = int64(optimal)
+	towerChallenge.Status.OptimalMoves = int64(optimal)
+	k := towerChallenge.Spec.InspectMove
+	if uint64(k) > optimal {
+		err := fmt.Errorf("inspectMove must be between 1 and %d", optimal)
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
+	}
+	// The summary of the solution in status only changes with the spec, so
+	// it is worked out once rather than for every batch.
+	summarized := towerChallenge.Status.SpecHash == hash && towerChallenge.Status.SolutionDigest != ""
+	classic := classicTower(towerChallenge.Spec, b)
+	if !summarized {
+		towerChallenge.Status.InspectedMove = nil
+		if k > 0 && classic {
+			if towerChallenge.Status.InspectedMove, err = inspectMove(b, towerChallenge.Spec.Discs, k); err != nil {
+				return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
+			}
+		}
 	}
 
-	if err := cleanupOldConfigMaps(ctx, r, req.Namespace, towerChallenge, validNames); err != nil {
-		log.Error(err, "Failed to clean up old ConfigMaps")
-		return ctrl.Result{}, err
+	moves := r.solutions.get(towerChallenge.UID, hash)
+	if moves == nil {
+		if err := r.setProgress(ctx, &towerChallenge, webappv1alpha1.Solving()); err != nil {
+			return ctrl.Result{}, err
+		}
+		solveStart := time.Now()
+		sol, err := solveChallenge(towerChallenge.Spec, b, start, goal)
+		if err != nil {
+			log.Error(err, "Failed to solve TowerChallenge")
+			return r.markError(ctx, &towerChallenge, err)
+		}
+		solveTime := time.Since(solveStart)
+		solveDuration.WithLabelValues(discsLabel(towerChallenge.Spec.Discs)).Observe(solveTime.Seconds())
+		r.Recorder.Eventf(&towerChallenge, corev1.EventTypeNormal, eventReasonSolved,
+			"Solved %d discs in %d moves in %s", towerChallenge.Spec.Discs, sol.total, solveTime.Round(time.Microsecond))
+		moves = newMoveSource(b, sol)
+		r.solutions.put(towerChallenge.UID, hash, moves)
+	}
+	if !summarized {
+		sum, err := summarize(moves, r.stepsPreview())
+		if err != nil {
+			return r.markError(ctx, &towerChallenge, err)
+		}
+		towerChallenge.Status.Steps = sum.steps
+		towerChallenge.Status.StepsOmitted = sum.omitted
+		towerChallenge.Status.StepsDigest = sum.stepsDigest
+		towerChallenge.Status.SolutionDigest = sum.solutionDigest
+		towerChallenge.Status.Splits = nil
+		for _, split := range moves.sol.splits {
+			towerChallenge.Status.Splits = append(towerChallenge.Status.Splits, webappv1alpha1.FrameStewartSplit{
+				Discs:  split.Discs,
+				Pegs:   split.Pegs,
+				Parked: split.Parked,
+			})
+		}
+		if k > 0 && !classic {
+			records, err := moves.Records(k, k)
+			if err != nil {
+				return r.markError(ctx, &towerChallenge, err)
+			}
+			towerChallenge.Status.InspectedMove = &records[0].MoveSnapshot
+		}
+	}
+	namespace := targetNamespace(&towerChallenge)
+	if err := r.Get(ctx, client.ObjectKey{Name: namespace}, &corev1.Namespace{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			// Namespaces are not watched, so the challenge is checked again
+			// after a while in case the namespace has been created.
+			err = fmt.Errorf("target namespace %q does not exist", namespace)
+			result, err := r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
+			if err == nil {
+				result.RequeueAfter = missingNamespaceRetry
+			}
+			return result, err
+		}
+		log.Error(err, "Failed to get target namespace", "namespace", namespace)
+		return r.markError(ctx, &towerChallenge, err)
+	}
+
+	kind := towerChallenge.Spec.Output.Kind
+	if kind == "" {
+		kind = webappv1alpha1.OutputConfigMap
+	}
+	sinks := r.moveSinks()
+	sink, ok := sinks[kind]
+	if !ok {
+		err := fmt.Errorf("unknown output kind %q", kind)
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
+	}
+	prefix, perObject, err := sink.Layout(&towerChallenge, moves)
+	if err != nil {
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
+	}
+	// How many moves a chunk holds is only known once the solution is, so
+	// the object ceiling of the chunked kind is checked here.
+	if objects := (moves.Total() + perObject - 1) / perObject; uint64(objects) > r.maxObjects() {
+		err := fmt.Errorf("the moves take %d objects of %d moves each, more than the limit of %d", objects, perObject, r.maxObjects())
+		return r.markFailed(ctx, &towerChallenge, err, webappv1alpha1.ValidationFailed(err))
+	}
+	// The cursor in status only carries over while the moves are written for
+	// the same spec, kind, namespace and layout; otherwise publication starts
+	// over. A finished publication that got here has lost objects and starts
+	// over too. Under the same layout the objects written before are
+	// overwritten, and counted as updated.
+	status := &towerChallenge.Status
+	sameLayout := status.OutputKind == kind && status.TargetNamespace == namespace &&
+		status.ArtifactPrefix == prefix && status.MovesPerArtifact == perObject
+	var existing func(name string) bool
+	if sameLayout {
+		existing = previouslyWritten(*status, r.publishBatchSize())
+	}
+	if !sameLayout || status.SpecHash != hash || status.PublishedMoves >= status.TotalMoves {
+		// Remove the moves left in the previous namespace when it was changed.
+		if previous := status.TargetNamespace; previous != "" && previous != namespace {
+			if err := eachObjectKind(sinks, func(otherKind webappv1alpha1.OutputKind, other MoveSink) error {
+				return other.Prune(ctx, &towerChallenge, previous, nil)
+			}); err != nil {
+				log.Error(err, "Failed to clean up moves in previous namespace", "namespace", previous)
+				return r.markError(ctx, &towerChallenge, err)
+			}
+		}
+		status.SpecHash = hash
+		status.OutputKind = kind
+		status.TargetNamespace = namespace
+		status.PublishedMoves = 0
+		status.ArtifactPrefix = prefix
+		status.MovesPerArtifact = perObject
+		status.ArtifactCount = 0
+	}
+
+	// Objects written before owner references were set are adopted first, so
+	// they are updated, pruned and garbage-collected like any other.
+	if err := eachObjectKind(sinks, func(otherKind webappv1alpha1.OutputKind, other MoveSink) error {
+		if err := other.Adopt(ctx, &towerChallenge, namespace); err != nil {
+			log.Error(err, "Failed to adopt existing moves", "output", otherKind)
+			return err
+		}
+		return nil
+	}); err != nil {
+		return r.markError(ctx, &towerChallenge, err)
+	}
+	chunks, publishErr := sink.Publish(ctx, &towerChallenge, namespace, moves, status.PublishedMoves, r.publishBatchSize(), existing)
+	status.MissingMoves = nil
+	if publishErr != nil {
+		// The cursor only moves past objects written before the first failure;
+		// the rest of the batch is written again on the next attempt.
+		var failed *PublishError
+		if errors.As(publishErr, &failed) {
+			status.MissingMoves = failed.Missing
+			chunks = writtenBefore(chunks, failed.Missing)
+		}
+	}
+	for _, chunk := range chunks {
+		status.ArtifactCount++
+		status.PublishedMoves = chunk.LastMove
+	}
+	status.ConfigMapNames = nil
+	status.ConfigMapsCreated = false
+	status.Progress = progress(status.PublishedMoves, status.TotalMoves)
+	outstandingMoves.WithLabelValues(towerChallenge.Name).Set(float64(status.TotalMoves - status.PublishedMoves))
+
+	if publishErr != nil {
+		// Returning the error retries the request with exponential backoff.
+		log.Error(publishErr, "Failed to publish moves", "output", kind, "missing", len(status.MissingMoves))
+		r.Recorder.Event(&towerChallenge, corev1.EventTypeWarning, eventReasonWriteFailed, publishErr.Error())
+		status.Phase = "Degraded"
+		status.ErrorMessage = publishErr.Error()
+		status.SetConditions(xpv1.Unavailable().WithMessage(publishErr.Error()), xpv1.ReconcileError(publishErr))
+		if err := r.applyStatus(ctx, &towerChallenge); err != nil {
+			log.Error(err, "Failed to update TowerChallenge status")
+		}
+		return ctrl.Result{}, publishErr
+	}
+
+	if status.PublishedMoves < status.TotalMoves {
+		status.Phase = "Publishing"
+		status.SetConditions(webappv1alpha1.WritingMoves().WithMessage(
+			fmt.Sprintf("published %d of %d moves", status.PublishedMoves, status.TotalMoves)))
+		if err := r.applyStatus(ctx, &towerChallenge); err != nil {
+			log.Error(err, "Failed to update TowerChallenge status")
+			return ctrl.Result{}, err
+		}
+		log.Info("Published a batch of moves", "published", status.PublishedMoves, "total", status.TotalMoves)
+		// A fixed delay lets other challenges in while a long solution is
+		// written, without the backoff a plain requeue goes through.
+		return ctrl.Result{RequeueAfter: publishRequeueDelay}, nil
 	}
 
-	towerChallenge.Status.ConfigMapNames = configMapNames
-	towerChallenge.Status.Phase = "Completed"
-	towerChallenge.Status.EndTime = metav1.Time{Time: time.Now()}
+	written := func(name string) bool { return writtenArtifact(&towerChallenge, name) }
+	// Remove what other output kinds wrote before the kind was changed. Sinks
+	// that write the same kind of object as the active one are left to it.
+	for otherKind, other := range sinks {
+		if otherKind == kind || other.ObjectKind() == sink.ObjectKind() {
+			continue
+		}
+		if err := other.Prune(ctx, &towerChallenge, namespace, nil); err != nil {
+			log.Error(err, "Failed to clean up old moves", "output", otherKind)
+			return r.markError(ctx, &towerChallenge, err)
+		}
+	}
+	if err := sink.Prune(ctx, &towerChallenge, namespace, written); err != nil {
+		log.Error(err, "Failed to clean up old moves", "output", kind)
+		return r.markError(ctx, &towerChallenge, err)
+	}
+
+	status.Phase = "Completed"
+	status.ConfigMapsCreated = sink.ObjectKind() == "ConfigMap"
+	status.ObservedGeneration = towerChallenge.Generation
+	status.ErrorMessage = ""
+	status.SetConditions(xpv1.Available(), xpv1.ReconcileSuccess())
+	status.EndTime = metav1.Time{Time: time.Now()}
+	// Every object was just written from the solution.
+	status.VerifiedTime = status.EndTime
 
-	if err := r.Status().Update(ctx, &towerChallenge); err != nil {
+	if err := r.applyStatus(ctx, &towerChallenge); err != nil {
 		log.Error(err, "Failed to update TowerChallenge status")
 		return ctrl.Result{}, err
 	}
 
+	r.solutions.forget(towerChallenge.UID)
 	log.Info("Reconciled TowerChallenge successfully")
+	return ctrl.Result{RequeueAfter: r.verifyPeriod()}, nil
+}
+
+// finalize applies the deletion policy of tc to the objects holding its moves,
+// then removes the finalizer so the deletion can complete.
+func (r *TowerChallengeReconciler) finalize(ctx context.Context, tc *webappv1alpha1.TowerChallenge) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(tc, challengeFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	policy := tc.Spec.DeletionPolicy
+	if policy == "" {
+		policy = xpv1.DeletionDelete
+	}
+	tc.Status.Phase = "Deleting"
+	tc.Status.SetConditions(xpv1.Deleting().WithMessage(fmt.Sprintf("applying deletion policy %s to the generated moves", policy)))
+	if err := r.applyStatus(ctx, tc); err != nil {
+		log.Error(err, "Failed to update TowerChallenge status")
+		return ctrl.Result{}, err
+	}
+
+	if err := eachObjectKind(r.moveSinks(), func(kind webappv1alpha1.OutputKind, sink MoveSink) error {
+		for _, namespace := range writtenNamespaces(tc) {
+			var err error
+			if policy == xpv1.DeletionOrphan {
+				err = sink.Release(ctx, tc, namespace)
+			} else {
+				err = sink.Prune(ctx, tc, namespace, nil)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Error(err, "Failed to clean up moves", "deletionPolicy", policy)
+		tc.Status.ErrorMessage = err.Error()
+		tc.Status.SetConditions(xpv1.ReconcileError(err))
+		if statusErr := r.applyStatus(ctx, tc); statusErr != nil {
+			log.Error(statusErr, "Failed to update TowerChallenge status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	controllerutil.RemoveFinalizer(tc, challengeFinalizer)
+	if err := r.Update(ctx, tc, fieldOwner); err != nil {
+		log.Error(err, "Failed to remove finalizer")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	outstandingMoves.DeleteLabelValues(tc.Name)
+	r.solutions.forget(tc.UID)
+	log.Info("Cleaned up TowerChallenge", "deletionPolicy", policy)
 	return ctrl.Result{}, nil
 }
 
-func validateTowerChallenge(tc webappv1alpha1.TowerChallenge) error {
-	if tc.Spec.Discs <= 0 {
-		return errors.New("the number of discs must be positive")
+// writtenBefore returns the chunks that end before the first of missing.
+func writtenBefore(chunks, missing []webappv1alpha1.MoveChunk) []webappv1alpha1.MoveChunk {
+	if len(missing) == 0 {
+		return chunks
 	}
-	return nil
+	first := missing[0].FirstMove
+	for _, m := range missing {
+		if m.FirstMove < first {
+			first = m.FirstMove
+		}
+	}
+	var kept []webappv1alpha1.MoveChunk
+	for _, chunk := range chunks {
+		if chunk.LastMove < first {
+			kept = append(kept, chunk)
+		}
+	}
+	return kept
 }
 
-func manageConfigMaps(ctx context.Context, r *TowerChallengeReconciler, namespace string, tc webappv1alpha1.TowerChallenge, steps []string) []string {
-	var configMapNames []string
-	existingCMs := &corev1.ConfigMapList{}
-	listOpts := []client.ListOption{
-		client.InNamespace(namespace),
-		client.MatchingLabels{"challenge": tc.Name},
+// previouslyWritten reports the objects the last attempt to publish the
+// moves laid out in status wrote: those it lists as written and, when that
+// attempt failed, the others of its batch of batch objects that were not
+// missing. Writing them again updates them rather than creating them.
+func previouslyWritten(status webappv1alpha1.TowerChallengeStatus, batch int) func(name string) bool {
+	last := status.ArtifactCount
+	missing := make(map[string]bool, len(status.MissingMoves))
+	for _, chunk := range status.MissingMoves {
+		missing[chunk.Name] = true
 	}
-	if err := r.List(ctx, existingCMs, listOpts...); err != nil {
-		log.FromContext(ctx).Error(err, "Unable to list ConfigMaps")
-		return nil
+	if len(missing) > 0 {
+		last += int64(batch)
+	}
+	return func(name string) bool {
+		suffix, ok := strings.CutPrefix(name, status.ArtifactPrefix)
+		if !ok || status.ArtifactPrefix == "" || missing[name] {
+			return false
+		}
+		i, err := strconv.ParseInt(suffix, 10, 64)
+		return err == nil && i >= 1 && i <= last && strconv.FormatInt(i, 10) == suffix
 	}
+}
 
-	existingCMsMap := make(map[string]*corev1.ConfigMap)
-	for _, cm := range existingCMs.Items {
-		existingCMsMap[cm.Name] = cm.DeepCopy()
+// progress renders how much of total has been published as a percentage.
+func progress(published, total int64) string {
+	if total == 0 {
+		return "100%"
 	}
+	return fmt.Sprintf("%d%%", published*100/total)
+}
 
-	for i, step := range steps {
-		cmName := fmt.Sprintf("%s-move-%d", tc.Name, i+1)
-		cm, found := existingCMsMap[cmName]
-		if found {
-			// Refetch the latest version of the ConfigMap to ensure updates are applied on the latest version
-			latestCM := &corev1.ConfigMap{}
-			err := r.Get(ctx, client.ObjectKey{Name: cmName, Namespace: namespace}, latestCM)
-			if err != nil {
-				log.FromContext(ctx).Error(err, "Failed to fetch the latest version of ConfigMap", "ConfigMap", cmName)
-				continue // skip this iteration if we cannot fetch the latest version
-			}
-			latestCM.Data = map[string]string{"move": step}
-			if err := r.Update(ctx, latestCM); err != nil {
-				if kerrors.IsConflict(err) {
-					log.FromContext(ctx).Info("Conflict detected, retrying update", "ConfigMap", cmName)
-					continue // Optionally, add a limit to retries or delay retries
-				}
-				log.FromContext(ctx).Error(err, "Failed to update ConfigMap", "ConfigMap", cmName)
-				return nil // Return or handle the error appropriately
-			}
-		} else {
-			cm = &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      cmName,
-					Namespace: namespace,
-					Labels:    map[string]string{"challenge": tc.Name},
-				},
-				Data: map[string]string{"move": step},
-			}
-			if err := r.Create(ctx, cm); err != nil {
-				log.FromContext(ctx).Error(err, "Failed to create ConfigMap", "ConfigMap", cmName)
-			}
+// specHash is a digest of everything in spec that shapes the moves written
+// for it. The deletion policy only matters once the challenge is deleted.
+func specHash(spec webappv1alpha1.TowerChallengeSpec) (string, error) {
+	spec.DeletionPolicy = ""
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// upToDate reports whether the moves of tc were written for its current spec,
+// whose digest is hash, and are all still in place. Every object is checked
+// against the data digest it carries, which takes no solve; once per
+// verification period it is compared with the one built from the solution
+// instead, which also catches data changed along with its digest. Objects
+// that went missing or were changed are written again on their own. The
+// generation is not compared: it also moves for fields the hash leaves out.
+func (r *TowerChallengeReconciler) upToDate(ctx context.Context, tc *webappv1alpha1.TowerChallenge, hash string) (bool, error) {
+	status := tc.Status
+	if status.SpecHash != hash ||
+		status.GetCondition(xpv1.TypeReady).Reason != xpv1.ReasonAvailable ||
+		status.TargetNamespace != targetNamespace(tc) || status.ArtifactPrefix == "" {
+		return false, nil
+	}
+	sink, ok := r.moveSinks()[status.OutputKind]
+	if !ok {
+		return false, nil
+	}
+	verify := r.nextVerification(tc) == 0
+	var stale []int64
+	if !verify {
+		var err error
+		if stale, err = sink.Stale(ctx, tc, status.TargetNamespace); err != nil || len(stale) == 0 {
+			return err == nil, err
+		}
+	}
+
+	moves := r.solutions.get(tc.UID, hash)
+	if moves == nil {
+		// A spec that no longer solves is left to the full reconcile to report.
+		b, err := newBoard(tc.Spec)
+		if err != nil {
+			return false, nil
+		}
+		start, goal, err := b.resolveStates(tc.Spec)
+		if err != nil {
+			return false, nil
+		}
+		sol, err := solveChallenge(tc.Spec, b, start, goal)
+		if err != nil {
+			return false, nil
+		}
+		moves = newMoveSource(b, sol)
+	}
+	if verify {
+		var err error
+		if stale, err = sink.Verify(ctx, tc, status.TargetNamespace, moves); err != nil {
+			return false, err
+		}
+	}
+	if len(stale) > 0 {
+		if err := r.rewriteStale(ctx, tc, sink, moves, stale); err != nil {
+			return false, err
+		}
+	}
+	if verify {
+		tc.Status.VerifiedTime = metav1.Now()
+	}
+	return true, nil
+}
+
+// rewriteStale writes the objects numbered stale again from moves, as laid
+// out in the status of tc.
+func (r *TowerChallengeReconciler) rewriteStale(ctx context.Context, tc *webappv1alpha1.TowerChallenge, sink MoveSink, moves MoveSource, stale []int64) error {
+	names := make([]string, 0, min(len(stale), staleNamesReported))
+	for _, i := range stale[:cap(names)] {
+		names = append(names, artifactName(tc, i))
+	}
+	if len(stale) > len(names) {
+		names = append(names, "...")
+	}
+	log.FromContext(ctx).Info("Rewriting moves that are missing or were changed", "count", len(stale), "objects", names)
+	r.Recorder.Eventf(tc, corev1.EventTypeWarning, eventReasonStale,
+		"Rewriting %d missing or changed objects: %s", len(stale), strings.Join(names, ", "))
+	written := func(name string) bool { return writtenArtifact(tc, name) }
+	var errs []error
+	for _, i := range stale {
+		if _, err := sink.Publish(ctx, tc, tc.Status.TargetNamespace, moves, (i-1)*tc.Status.MovesPerArtifact, 1, written); err != nil {
+			errs = append(errs, err)
 		}
-		configMapNames = append(configMapNames, cmName)
 	}
+	if err := errors.Join(errs...); err != nil {
+		r.Recorder.Event(tc, corev1.EventTypeWarning, eventReasonWriteFailed, err.Error())
+		return err
+	}
+	return nil
+}
+
+// nextVerification is how long the moves of tc wait before they are next
+// compared with its solution, zero when that is due.
+func (r *TowerChallengeReconciler) nextVerification(tc *webappv1alpha1.TowerChallenge) time.Duration {
+	return max(time.Until(tc.Status.VerifiedTime.Add(r.verifyPeriod())), 0)
+}
+
+// targetNamespace is the namespace the moves of tc are written to.
+func targetNamespace(tc *webappv1alpha1.TowerChallenge) string {
+	if tc.Spec.TargetNamespace == "" {
+		return defaultTargetNamespace
+	}
+	return tc.Spec.TargetNamespace
+}
+
+// writtenNamespaces lists the namespaces that may hold moves of tc: the one
+// they were last written to and the one they are about to be written to.
+func writtenNamespaces(tc *webappv1alpha1.TowerChallenge) []string {
+	namespaces := []string{targetNamespace(tc)}
+	if previous := tc.Status.TargetNamespace; previous != "" && previous != namespaces[0] {
+		namespaces = append(namespaces, previous)
+	}
+	return namespaces
+}
+
+// applyStatus writes the status of tc with server-side apply under
+// fieldManager, then records the new resource version on tc.
+func (r *TowerChallengeReconciler) applyStatus(ctx context.Context, tc *webappv1alpha1.TowerChallenge) error {
+	applied := &webappv1alpha1.TowerChallenge{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: webappv1alpha1.GroupVersion.String(),
+			Kind:       "TowerChallenge",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: tc.Name, Namespace: tc.Namespace},
+		Status:     tc.Status,
+	}
+	if err := r.Status().Patch(ctx, applied, client.Apply, fieldOwner, client.ForceOwnership); err != nil {
+		return err
+	}
+	tc.ResourceVersion = applied.ResourceVersion
+	return nil
+}
+
+// markFailed records err in the status of tc, together with conditions
+// explaining it. A warning event is recorded with the reason of each
+// condition. The spec itself is at fault, so retrying would fail the same way
+// until it is changed, which triggers a reconcile of its own; only a failure
+// to write the status is returned.
+func (r *TowerChallengeReconciler) markFailed(ctx context.Context, tc *webappv1alpha1.TowerChallenge, err error, c ...xpv1.Condition) (ctrl.Result, error) {
+	for _, condition := range c {
+		r.Recorder.Event(tc, corev1.EventTypeWarning, string(condition.Reason), err.Error())
+	}
+	r.solutions.forget(tc.UID)
+	tc.Status.Phase = "Failed"
+	tc.Status.ErrorMessage = err.Error()
+	tc.Status.SetConditions(xpv1.Unavailable().WithMessage(err.Error()))
+	tc.Status.SetConditions(c...)
+	if statusErr := r.applyStatus(ctx, tc); statusErr != nil {
+		log.FromContext(ctx).Error(statusErr, "Failed to update TowerChallenge status")
+		return ctrl.Result{}, statusErr
+	}
+	return ctrl.Result{}, nil
+}
+
+// markError reports a transient err on the Synced condition of tc, leaving
+// its phase alone, and returns it so that the request is retried.
+func (r *TowerChallengeReconciler) markError(ctx context.Context, tc *webappv1alpha1.TowerChallenge, err error) (ctrl.Result, error) {
+	tc.Status.ErrorMessage = err.Error()
+	tc.Status.SetConditions(xpv1.ReconcileError(err))
+	if statusErr := r.applyStatus(ctx, tc); statusErr != nil {
+		log.FromContext(ctx).Error(statusErr, "Failed to update TowerChallenge status")
+	}
+	return ctrl.Result{}, err
+}
 
-	return configMapNames
+// setProgress records c on tc, writing the status only when the condition changes.
+func (r *TowerChallengeReconciler) setProgress(ctx context.Context, tc *webappv1alpha1.TowerChallenge, c xpv1.Condition) error {
+	if tc.Status.GetCondition(c.Type).Equal(c) {
+		return nil
+	}
+	tc.Status.SetConditions(c)
+	if err := r.applyStatus(ctx, tc); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update TowerChallenge status")
+		return err
+	}
+	return nil
+}
+
+// validateTowerChallenge rejects the specs the controller cannot solve,
+// including those with more than maxDiscs discs, whose solution can take
+// more than maxMoves moves or whose moves can take more than maxObjects
+// objects.
+func validateTowerChallenge(tc webappv1alpha1.TowerChallenge, maxDiscs int, maxMoves, maxObjects uint64) error {
+	if tc.Spec.Discs <= 0 {
+		return errors.New("the number of discs must be positive")
+	}
+	if limit := min(maxDiscs, solver.MaxDiscs-1); tc.Spec.Discs > limit {
+		return fmt.Errorf("the number of discs must not exceed %d", limit)
+	}
+	if tc.Spec.PegCount > webappv1alpha1.MaxPegs || len(tc.Spec.Pegs) > webappv1alpha1.MaxPegs {
+		return fmt.Errorf("at most %d pegs are supported", webappv1alpha1.MaxPegs)
+	}
+	if tc.Spec.InspectMove < 0 {
+		return errors.New("inspectMove must be positive")
+	}
+	customStates := len(tc.Spec.InitialState) > 0 || len(tc.Spec.TargetState) > 0
+	pegs := pegCount(tc.Spec)
+	if customStates && pegs > 3 {
+		return errors.New("initialState and targetState are only supported with 3 pegs")
+	}
+	switch tc.Spec.Variant {
+	case "", webappv1alpha1.VariantClassic:
+	case webappv1alpha1.VariantCyclic, webappv1alpha1.VariantAdjacent, webappv1alpha1.VariantBicolor:
+		if customStates || pegs > 3 {
+			return fmt.Errorf("the %s variant requires 3 pegs and the default initial and target states", tc.Spec.Variant)
+		}
+	default:
+		return fmt.Errorf("unknown variant %q", tc.Spec.Variant)
+	}
+	if moves := webappv1alpha1.MaxMoveCount(tc.Spec); moves > maxMoves {
+		return fmt.Errorf("solving %d discs can take %d moves, more than the limit of %d", tc.Spec.Discs, moves, maxMoves)
+	}
+	if objects, ok := webappv1alpha1.MaxObjectCount(tc.Spec); ok && objects > maxObjects {
+		return fmt.Errorf("writing the moves of %d discs one per object can take %d objects, more than the limit of %d", tc.Spec.Discs, objects, maxObjects)
+	}
+	return nil
 }
 
-func cleanupOldConfigMaps(ctx context.Context, r *TowerChallengeReconciler, namespace string, tc webappv1alpha1.TowerChallenge, validNames map[string]bool) error {
+func cleanupOldConfigMaps(ctx context.Context, r client.Client, recorder record.EventRecorder, namespace string, tc webappv1alpha1.TowerChallenge, keep func(name string) bool) error {
 	var allConfigMaps corev1.ConfigMapList
 	listOpts := []client.ListOption{
 		client.InNamespace(namespace),
-		client.MatchingLabels{"challenge": tc.Name},
+		client.MatchingLabels{challengeLabel: tc.Name},
 	}
 	if err := r.List(ctx, &allConfigMaps, listOpts...); err != nil {
 		log.FromContext(ctx).Error(err, "Failed to list ConfigMaps for cleanup")
 		return err
 	}
 
+	deleted := 0
 	for _, cm := range allConfigMaps.Items {
-		if _, isValid := validNames[cm.Name]; !isValid {
-			// If the ConfigMap name is not in the list of valid names, delete it
+		if !metav1.IsControlledBy(&cm, &tc) {
+			continue
+		}
+		if keep == nil || !keep(cm.Name) {
+			// If the ConfigMap is not one of the moves to keep, delete it
 			if err := r.Delete(ctx, &cm); err != nil {
 				if !kerrors.IsNotFound(err) {
+					artifactFailures.WithLabelValues("ConfigMap", operationDelete).Inc()
+					recorder.Eventf(&tc, corev1.EventTypeWarning, eventReasonPruneFailed, "Failed to delete ConfigMap %s/%s: %v", namespace, cm.Name, err)
 					log.FromContext(ctx).Error(err, "Failed to delete ConfigMap", "ConfigMap", cm.Name)
 					continue // continue with the next item
 				}
 			}
+			deleted++
+			artifactOperations.WithLabelValues("ConfigMap", operationDeleted).Inc()
 			log.FromContext(ctx).Info("Deleted old or invalid ConfigMap", "ConfigMap", cm.Name)
 		}
 	}
+	recordPruned(recorder, &tc, "ConfigMap", namespace, deleted)
 	return nil
 }
 
-func solveHanoi(n int, from, to, aux string) []string {
-	if n == 1 {
-		return []string{fmt.Sprintf("Move disk 1 from %s to %s", from, to)}
-	}
-	var moves []string
-	moves = append(moves, solveHanoi(n-1, from, aux, to)...)
-	moves = append(moves, fmt.Sprintf("Move disk %d from %s to %s", n, from, to))
-	moves = append(moves, solveHanoi(n-1, aux, to, from)...)
-	return moves
-}
-
 func (r *TowerChallengeReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&webappv1alpha1.TowerChallenge{}).
 		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Owns(&webappv1alpha1.TowerMove{}).
 		Complete(r)
 }
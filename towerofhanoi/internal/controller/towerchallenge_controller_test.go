@@ -18,16 +18,38 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/simulator"
+	"hanoi.com/towerofhanoi/pkg/solver"
 )
 
 var _ = Describe("TowerChallenge Controller", func() {
@@ -37,8 +59,7 @@ var _ = Describe("TowerChallenge Controller", func() {
 		ctx := context.Background()
 
 		typeNamespacedName := types.NamespacedName{
-			Name:      resourceName,
-			Namespace: "default", // TODO(user):Modify as needed
+			Name: resourceName,
 		}
 		towerchallenge := &webappv1alpha1.TowerChallenge{}
 
@@ -48,10 +69,12 @@ var _ = Describe("TowerChallenge Controller", func() {
 			if err != nil && errors.IsNotFound(err) {
 				resource := &webappv1alpha1.TowerChallenge{
 					ObjectMeta: metav1.ObjectMeta{
-						Name:      resourceName,
-						Namespace: "default",
+						Name: resourceName,
+					},
+					Spec: webappv1alpha1.TowerChallengeSpec{
+						Discs:           3,
+						TargetNamespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -69,8 +92,9 @@ var _ = Describe("TowerChallenge Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &TowerChallengeReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -82,3 +106,1320 @@ var _ = Describe("TowerChallenge Controller", func() {
 		})
 	})
 })
+
+// allRecords builds the record of every move of sol.
+func allRecords(b board, sol solution) []webappv1alpha1.MoveRecord {
+	moves := newMoveSource(b, sol)
+	records, err := moves.Records(1, moves.Total())
+	Expect(err).NotTo(HaveOccurred())
+	return records
+}
+
+var _ = Describe("TowerChallenge board", func() {
+	var b board
+
+	BeforeEach(func() {
+		var err error
+		b, err = newBoard(webappv1alpha1.TowerChallengeSpec{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("uses peg names and selectors from the spec", func() {
+		nb, err := newBoard(webappv1alpha1.TowerChallengeSpec{
+			Pegs: []string{"rack-1", "rack-2", "rack-3"},
+			From: "rack-1",
+			To:   "rack-2",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := nb.resolveStates(webappv1alpha1.TowerChallengeSpec{Discs: 1})
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 1}, nb, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(moveText(allRecords(nb, sol)[0])).To(Equal("Move disk 1 from rack-1 to rack-2"))
+	})
+
+	It("names extra pegs and keeps them as spares", func() {
+		nb, err := newBoard(webappv1alpha1.TowerChallengeSpec{PegCount: 5, To: "C"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nb.names).To(Equal([]string{"A", "B", "C", "D", "E"}))
+		Expect(nb.spares).To(Equal([]solver.Peg{1, 3, 4}))
+
+		_, err = newBoard(webappv1alpha1.TowerChallengeSpec{PegCount: 4, Pegs: []string{"A", "B", "C"}})
+		Expect(err).To(MatchError(ContainSubstring("4 pegs must be named")))
+	})
+
+	It("counts the named pegs when the peg count is left out", func() {
+		nb, err := newBoard(webappv1alpha1.TowerChallengeSpec{Pegs: []string{"a", "b", "c", "d"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nb.names).To(Equal([]string{"a", "b", "c", "d"}))
+		Expect(nb.spares).To(Equal([]solver.Peg{1, 2}))
+
+		spec := webappv1alpha1.TowerChallengeSpec{Discs: 3, Variant: webappv1alpha1.VariantCyclic, Pegs: nb.names}
+		Expect(validateTowerChallenge(webappv1alpha1.TowerChallenge{Spec: spec}, 20, math.MaxUint64, math.MaxUint64)).
+			To(MatchError(ContainSubstring("requires 3 pegs")))
+	})
+
+	It("rejects selectors that reference undefined pegs", func() {
+		_, err := newBoard(webappv1alpha1.TowerChallengeSpec{From: "D"})
+		Expect(err).To(MatchError(ContainSubstring("undefined peg")))
+		_, err = newBoard(webappv1alpha1.TowerChallengeSpec{From: "C"})
+		Expect(err).To(MatchError(ContainSubstring("different pegs")))
+		_, err = newBoard(webappv1alpha1.TowerChallengeSpec{Pegs: []string{"A", "A", "B"}})
+		Expect(err).To(MatchError(ContainSubstring("more than once")))
+	})
+
+	It("converts peg stacks into disc positions", func() {
+		state, err := b.parseState([]webappv1alpha1.PegState{
+			{Name: "A", Discs: []int{3, 1}},
+			{Name: "C", Discs: []int{2}},
+		}, 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal([]solver.Peg{b.source, b.target, b.source}))
+	})
+
+	It("rejects a larger disc on a smaller one", func() {
+		_, err := b.parseState([]webappv1alpha1.PegState{
+			{Name: "A", Discs: []int{1, 2}},
+		}, 2)
+		Expect(err).To(MatchError(ContainSubstring("placed on smaller disc")))
+	})
+
+	It("rejects missing, duplicated and unknown discs", func() {
+		_, err := b.parseState([]webappv1alpha1.PegState{{Name: "A", Discs: []int{2}}}, 2)
+		Expect(err).To(MatchError(ContainSubstring("not placed")))
+		_, err = b.parseState([]webappv1alpha1.PegState{{Name: "A", Discs: []int{2, 1}}, {Name: "B", Discs: []int{1}}}, 2)
+		Expect(err).To(MatchError(ContainSubstring("more than once")))
+		_, err = b.parseState([]webappv1alpha1.PegState{{Name: "D", Discs: []int{1}}}, 1)
+		Expect(err).To(MatchError(ContainSubstring("unknown peg")))
+	})
+})
+
+var _ = Describe("TowerChallenge solutions", func() {
+	solve := func(spec webappv1alpha1.TowerChallengeSpec) solution {
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := b.resolveStates(spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(spec, b, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+		return sol
+	}
+
+	It("reports the optimal move count of each variant", func() {
+		counts := map[webappv1alpha1.Variant]int{
+			webappv1alpha1.VariantClassic:  7,
+			webappv1alpha1.VariantCyclic:   21,
+			webappv1alpha1.VariantAdjacent: 26,
+			webappv1alpha1.VariantBicolor:  27,
+		}
+		for variant, want := range counts {
+			sol := solve(webappv1alpha1.TowerChallengeSpec{Discs: 3, Variant: variant})
+			Expect(sol.total).To(BeNumerically("==", want), string(variant))
+			Expect(sol.optimal).To(BeNumerically("==", want), string(variant))
+		}
+	})
+
+	It("plays only legal moves that reach the goal", func() {
+		specs := map[string]webappv1alpha1.TowerChallengeSpec{
+			"classic":  {Discs: 5},
+			"cyclic":   {Discs: 4, Variant: webappv1alpha1.VariantCyclic, From: "B", To: "A"},
+			"adjacent": {Discs: 4, Variant: webappv1alpha1.VariantAdjacent},
+			"bicolor":  {Discs: 3, Variant: webappv1alpha1.VariantBicolor},
+			"4 pegs":   {Discs: 6, PegCount: 4},
+			"states": {
+				Discs:        4,
+				InitialState: []webappv1alpha1.PegState{{Name: "A", Discs: []int{4, 1}}, {Name: "C", Discs: []int{3, 2}}},
+				TargetState:  []webappv1alpha1.PegState{{Name: "B", Discs: []int{4, 3, 2}}, {Name: "C", Discs: []int{1}}},
+			},
+		}
+		for name, spec := range specs {
+			b, err := newBoard(spec)
+			Expect(err).NotTo(HaveOccurred(), name)
+			start, goal, err := b.resolveStates(spec)
+			Expect(err).NotTo(HaveOccurred(), name)
+			sol, err := solveChallenge(spec, b, start, goal)
+			Expect(err).NotTo(HaveOccurred(), name)
+			rules, err := simulator.RulesFor(spec.Variant)
+			Expect(err).NotTo(HaveOccurred(), name)
+
+			sim, err := simulator.FromPegStates(b.names, b.pegStates(sol.start), rules)
+			Expect(err).NotTo(HaveOccurred(), name)
+			for _, rec := range allRecords(b, sol) {
+				Expect(sim.PlayRecord(rec)).To(Succeed(), name)
+			}
+			want := b.stacks(goal)
+			if spec.Variant == webappv1alpha1.VariantBicolor {
+				want[b.target] = doubleStack(want[b.target])
+			}
+			Expect(sim.Matches(want)).To(BeTrue(), name)
+		}
+	})
+
+	It("replays moves to describe the board", func() {
+		spec := webappv1alpha1.TowerChallengeSpec{Discs: 2, Variant: webappv1alpha1.VariantBicolor}
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		moves := newMoveSource(b, solve(spec))
+		_, err = moves.Records(3, 5)
+		Expect(err).NotTo(HaveOccurred())
+		records, err := moves.Records(2, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records[0].Pegs).To(Equal([]webappv1alpha1.PegState{
+			{Name: "A", Discs: []int{2, 2}},
+			{Name: "B"},
+			{Name: "C", Discs: []int{1, 1}},
+		}))
+	})
+
+	It("builds any range of classic moves from its first index", func() {
+		spec := webappv1alpha1.TowerChallengeSpec{Discs: 5}
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol := solve(spec)
+		Expect(sol.moves).To(BeEmpty())
+		all := allRecords(b, sol)
+		Expect(all).To(HaveLen(31))
+
+		moves := newMoveSource(b, sol)
+		for _, r := range [][2]int64{{20, 31}, {4, 9}, {10, 12}, {1, 1}} {
+			records, err := moves.Records(r[0], r[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(Equal(all[r[0]-1 : r[1]]))
+		}
+		_, err = moves.Records(31, 32)
+		Expect(err).To(MatchError(ContainSubstring("not between 1 and 31")))
+	})
+})
+
+var _ = Describe("TowerChallenge move records", func() {
+	It("writes a versioned record next to the step text", func() {
+		spec := webappv1alpha1.TowerChallengeSpec{Discs: 2}
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := b.resolveStates(spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(spec, b, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+
+		records := allRecords(b, sol)
+		Expect(records).To(HaveLen(3))
+		data, err := moveData(records[1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(HaveKeyWithValue(moveKey, "Move disk 2 from A to C"))
+		Expect(data[moveJSONKey]).To(MatchJSON(`{
+			"version": "v1",
+			"index": 2,
+			"disc": 2,
+			"from": "A",
+			"to": "C",
+			"pegs": [{"name": "A"}, {"name": "B", "discs": [1]}, {"name": "C", "discs": [2]}]
+		}`))
+		Expect(data[moveYAMLKey]).To(MatchYAML(data[moveJSONKey]))
+	})
+
+	It("packs consecutive moves into chunks within the byte budget", func() {
+		spec := webappv1alpha1.TowerChallengeSpec{Discs: 6, Output: webappv1alpha1.Output{MaxChunkBytes: 4096}}
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := b.resolveStates(spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(spec, b, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+		moves := newMoveSource(b, sol)
+
+		tc := &webappv1alpha1.TowerChallenge{ObjectMeta: metav1.ObjectMeta{Name: "packed", UID: "packed-uid"}, Spec: spec}
+		c := newFakeClient()
+		sink := &chunkedConfigMapSink{Client: c}
+		prefix, perObject, err := sink.Layout(tc, moves)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prefix).To(Equal("packed-moves-"))
+		Expect(perObject).To(BeNumerically(">", 1))
+		chunks, err := sink.Publish(context.Background(), tc, "default", moves, 0, 0, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(chunks)).To(BeNumerically(">", 1))
+
+		next := int64(1)
+		for i, chunk := range chunks {
+			Expect(chunk.Name).To(Equal(fmt.Sprintf("%s%d", prefix, i+1)))
+			Expect(chunk.FirstMove).To(Equal(next))
+			cm := &corev1.ConfigMap{}
+			Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: chunk.Name}, cm)).To(Succeed())
+			size := 0
+			for key, value := range cm.Data {
+				size += len(key) + len(value)
+			}
+			Expect(size).To(BeNumerically("<=", 4096))
+			Expect(cm.Data).To(HaveLen(2 * int(chunk.LastMove-chunk.FirstMove+1)))
+			next = chunk.LastMove + 1
+		}
+		Expect(next).To(Equal(moves.Total() + 1))
+
+		tc.Spec.Output.MaxChunkBytes = 64
+		_, _, err = sink.Layout(tc, moves)
+		Expect(err).To(MatchError(ContainSubstring("more than the chunk budget")))
+	})
+
+	It("previews the first and last steps of long solutions", func() {
+		spec := webappv1alpha1.TowerChallengeSpec{Discs: 4}
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := b.resolveStates(spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(spec, b, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+		moves := newMoveSource(b, sol)
+
+		full, err := summarize(moves, 8)
+		Expect(err).NotTo(HaveOccurred())
+		all := full.steps
+		Expect(all).To(HaveLen(15))
+		Expect(full.omitted).To(BeZero())
+
+		preview, err := summarize(moves, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(preview.steps).To(Equal([]string{all[0], all[1], all[13], all[14]}))
+		Expect(preview.omitted).To(Equal(int64(11)))
+		Expect(preview.stepsDigest).To(Equal(full.stepsDigest))
+		Expect(full.stepsDigest).To(Equal(fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(strings.Join(all, "\n")+"\n")))))
+
+		var records []byte
+		for _, rec := range allRecords(b, sol) {
+			data, err := json.Marshal(rec)
+			Expect(err).NotTo(HaveOccurred())
+			records = append(append(records, data...), '\n')
+		}
+		Expect(full.solutionDigest).To(Equal(fmt.Sprintf("sha256:%x", sha256.Sum256(records))))
+	})
+})
+
+// verifyCountingSink counts the full verifications of the sink it wraps.
+type verifyCountingSink struct {
+	MoveSink
+	verified int
+}
+
+func (s *verifyCountingSink) Verify(ctx context.Context, tc *webappv1alpha1.TowerChallenge, namespace string, moves MoveSource) ([]int64, error) {
+	s.verified++
+	return s.MoveSink.Verify(ctx, tc, namespace, moves)
+}
+
+// newFakeClient returns a fake client holding objs. The fake client cannot
+// apply patches, so they are replayed as a create or a full update, which is
+// what server-side apply amounts to for objects with a single field manager.
+func newFakeClient(objs ...client.Object) client.WithWatch {
+	s := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+	Expect(webappv1alpha1.AddToScheme(s)).To(Succeed())
+	return fake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(objs...).
+		WithStatusSubresource(&webappv1alpha1.TowerChallenge{}, &webappv1alpha1.TowerAttempt{}).
+		WithIndex(&webappv1alpha1.TowerAttempt{}, attemptChallengeField, attemptChallenge).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if patch.Type() != types.ApplyPatchType {
+					return c.Patch(ctx, obj, patch, opts...)
+				}
+				existing := obj.DeepCopyObject().(client.Object)
+				if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); errors.IsNotFound(err) {
+					return c.Create(ctx, obj)
+				} else if err != nil {
+					return err
+				}
+				obj.SetResourceVersion(existing.GetResourceVersion())
+				return c.Update(ctx, obj)
+			},
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				if patch.Type() != types.ApplyPatchType {
+					return c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+				}
+				existing := obj.DeepCopyObject().(client.Object)
+				if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+					return err
+				}
+				obj.SetResourceVersion(existing.GetResourceVersion())
+				return c.SubResource(subResource).Update(ctx, obj)
+			},
+		}).
+		Build()
+}
+
+var _ = Describe("TowerChallenge ownership", func() {
+	const namespace = "tower-challenge"
+	var (
+		ctx context.Context
+		c   client.Client
+		tc  *webappv1alpha1.TowerChallenge
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tc = &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "owned", UID: "owned-uid"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2},
+		}
+		orphan := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      "owned-move-9",
+			Namespace: namespace,
+			Labels:    map[string]string{challengeLabel: tc.Name},
+		}}
+		c = newFakeClient(tc, orphan)
+	})
+
+	It("owns every object it publishes", func() {
+		spec := tc.Spec
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := b.resolveStates(spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(spec, b, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+
+		for kind, sink := range newMoveSinks(c, record.NewFakeRecorder(100)) {
+			chunks, err := sink.Publish(ctx, tc, namespace, newMoveSource(b, sol), 0, 0, nil)
+			Expect(err).NotTo(HaveOccurred(), string(kind))
+			Expect(chunks).NotTo(BeEmpty())
+		}
+
+		var cms corev1.ConfigMapList
+		Expect(c.List(ctx, &cms, client.InNamespace(namespace))).To(Succeed())
+		for _, cm := range cms.Items {
+			if cm.Name != "owned-move-9" {
+				Expect(metav1.IsControlledBy(&cm, tc)).To(BeTrue(), cm.Name)
+			}
+		}
+		var secrets corev1.SecretList
+		Expect(c.List(ctx, &secrets, client.InNamespace(namespace))).To(Succeed())
+		Expect(secrets.Items).To(HaveLen(3))
+		for _, secret := range secrets.Items {
+			Expect(metav1.IsControlledBy(&secret, tc)).To(BeTrue(), secret.Name)
+		}
+	})
+
+	It("adopts labelled objects without a controller before pruning them", func() {
+		sink := &configMapSink{Client: c, Recorder: record.NewFakeRecorder(100)}
+		Expect(sink.Prune(ctx, tc, namespace, nil)).To(Succeed())
+		orphan := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "owned-move-9", Namespace: namespace}, orphan)).To(Succeed())
+
+		Expect(sink.Adopt(ctx, tc, namespace)).To(Succeed())
+		Expect(c.Get(ctx, types.NamespacedName{Name: "owned-move-9", Namespace: namespace}, orphan)).To(Succeed())
+		Expect(metav1.IsControlledBy(orphan, tc)).To(BeTrue())
+
+		Expect(sink.Prune(ctx, tc, namespace, nil)).To(Succeed())
+		err := c.Get(ctx, types.NamespacedName{Name: "owned-move-9", Namespace: namespace}, orphan)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("leaves labelled objects alone that it did not write", func() {
+		labels := map[string]string{challengeLabel: tc.Name}
+		foreign := []client.Object{
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owned-settings", Namespace: namespace, Labels: labels}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owned-move-09", Namespace: namespace, Labels: labels}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "owned-move-1", Namespace: namespace, Labels: labels}},
+			&webappv1alpha1.TowerMove{ObjectMeta: metav1.ObjectMeta{Name: "owned-move-1", Namespace: namespace, Labels: labels}},
+		}
+		for _, obj := range foreign {
+			Expect(c.Create(ctx, obj)).To(Succeed())
+		}
+		for kind, sink := range newMoveSinks(c, record.NewFakeRecorder(100)) {
+			Expect(sink.Adopt(ctx, tc, namespace)).To(Succeed(), string(kind))
+			Expect(sink.Prune(ctx, tc, namespace, nil)).To(Succeed(), string(kind))
+		}
+		for _, obj := range foreign {
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+			Expect(metav1.GetControllerOf(obj)).To(BeNil(), obj.GetName())
+		}
+	})
+})
+
+var _ = Describe("TowerChallenge sinks", func() {
+	const namespace = "tower-challenge"
+
+	// sinkCase describes how to read and tamper with the objects of a sink.
+	type sinkCase struct {
+		newSink func(c client.Client) MoveSink
+		newObj  func() client.Object
+		tamper  func(obj client.Object)
+	}
+	cases := map[webappv1alpha1.OutputKind]sinkCase{
+		webappv1alpha1.OutputConfigMap: {
+			newSink: func(c client.Client) MoveSink {
+				return &configMapSink{Client: c, Recorder: record.NewFakeRecorder(100)}
+			},
+			newObj: func() client.Object { return &corev1.ConfigMap{} },
+			tamper: func(obj client.Object) { obj.(*corev1.ConfigMap).Data[moveKey] = "Move disk 3 from A to B" },
+		},
+		webappv1alpha1.OutputChunkedConfigMap: {
+			newSink: func(c client.Client) MoveSink {
+				return &chunkedConfigMapSink{Client: c, Recorder: record.NewFakeRecorder(100)}
+			},
+			newObj: func() client.Object { return &corev1.ConfigMap{} },
+			tamper: func(obj client.Object) { obj.(*corev1.ConfigMap).Data["move-1"] = "Move disk 3 from A to B" },
+		},
+		webappv1alpha1.OutputSecret: {
+			newSink: func(c client.Client) MoveSink { return &secretSink{Client: c, Recorder: record.NewFakeRecorder(100)} },
+			newObj:  func() client.Object { return &corev1.Secret{} },
+			tamper:  func(obj client.Object) { obj.(*corev1.Secret).Data[moveKey] = []byte("Move disk 3 from A to B") },
+		},
+		webappv1alpha1.OutputTowerMove: {
+			newSink: func(c client.Client) MoveSink {
+				return &towerMoveSink{Client: c, Recorder: record.NewFakeRecorder(100)}
+			},
+			newObj: func() client.Object { return &webappv1alpha1.TowerMove{} },
+			tamper: func(obj client.Object) { obj.(*webappv1alpha1.TowerMove).Spec.Step = "Move disk 3 from A to B" },
+		},
+	}
+
+	// publish writes every move of a challenge with sink and records the
+	// layout in its status, as Reconcile does.
+	publish := func(c client.Client, sink MoveSink) (*webappv1alpha1.TowerChallenge, MoveSource) {
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "sunk", UID: "sunk-uid"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 3, Output: webappv1alpha1.Output{MaxChunkBytes: 1024}},
+		}
+		b, err := newBoard(tc.Spec)
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := b.resolveStates(tc.Spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(tc.Spec, b, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+		moves := newMoveSource(b, sol)
+		sum, err := summarize(moves, 0)
+		Expect(err).NotTo(HaveOccurred())
+		tc.Status.SolutionDigest = sum.solutionDigest
+
+		prefix, perObject, err := sink.Layout(tc, moves)
+		Expect(err).NotTo(HaveOccurred())
+		chunks, err := sink.Publish(context.Background(), tc, namespace, moves, 0, 0, nil)
+		Expect(err).NotTo(HaveOccurred())
+		tc.Status.ArtifactPrefix = prefix
+		tc.Status.MovesPerArtifact = perObject
+		tc.Status.ArtifactCount = int64(len(chunks))
+		Expect(tc.Status.ArtifactCount).To(BeNumerically(">", 1))
+		return tc, moves
+	}
+
+	It("publishes every move in the objects laid out for it", func() {
+		for kind, sc := range cases {
+			c := newFakeClient()
+			sink := sc.newSink(c)
+			tc, moves := publish(c, sink)
+			Expect(tc.Status.ArtifactCount).To(Equal((moves.Total()+tc.Status.MovesPerArtifact-1)/tc.Status.MovesPerArtifact), string(kind))
+
+			var records []webappv1alpha1.MoveRecord
+			for i := int64(1); i <= tc.Status.ArtifactCount; i++ {
+				obj := sc.newObj()
+				Expect(c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: artifactName(tc, i)}, obj)).To(Succeed(), string(kind))
+				Expect(metav1.IsControlledBy(obj, tc)).To(BeTrue(), string(kind))
+				Expect(obj.GetLabels()).To(HaveKeyWithValue(challengeLabel, tc.Name), string(kind))
+				Expect(obj.GetAnnotations()).To(HaveKeyWithValue(solutionDigestAnnotation, tc.Status.SolutionDigest), string(kind))
+				switch o := obj.(type) {
+				case *corev1.ConfigMap:
+					for key, value := range o.Data {
+						if strings.HasSuffix(key, ".json") {
+							var rec webappv1alpha1.MoveRecord
+							Expect(json.Unmarshal([]byte(value), &rec)).To(Succeed())
+							records = append(records, rec)
+						}
+					}
+				case *corev1.Secret:
+					var rec webappv1alpha1.MoveRecord
+					Expect(json.Unmarshal(o.Data[moveJSONKey], &rec)).To(Succeed())
+					records = append(records, rec)
+				case *webappv1alpha1.TowerMove:
+					records = append(records, o.Spec.Record)
+				}
+			}
+			want, err := moves.Records(1, moves.Total())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(ConsistOf(want), string(kind))
+		}
+	})
+
+	It("reports the objects that are missing or hold other moves", func() {
+		ctx := context.Background()
+		for kind, sc := range cases {
+			c := newFakeClient()
+			sink := sc.newSink(c)
+			tc, moves := publish(c, sink)
+			Expect(sink.Stale(ctx, tc, namespace)).To(BeEmpty(), string(kind))
+			Expect(sink.Verify(ctx, tc, namespace, moves)).To(BeEmpty(), string(kind))
+
+			// Changing the data alone breaks its digest.
+			obj := sc.newObj()
+			Expect(c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: artifactName(tc, 1)}, obj)).To(Succeed())
+			sc.tamper(obj)
+			Expect(c.Update(ctx, obj)).To(Succeed())
+			Expect(sink.Stale(ctx, tc, namespace)).To(Equal([]int64{1}), string(kind))
+
+			// Changing it along with its digest takes the solution to catch.
+			digest, _, err := objectDigest(obj)
+			Expect(err).NotTo(HaveOccurred())
+			obj.GetAnnotations()[dataDigestAnnotation] = digest
+			Expect(c.Update(ctx, obj)).To(Succeed())
+			Expect(sink.Stale(ctx, tc, namespace)).To(BeEmpty(), string(kind))
+			Expect(sink.Verify(ctx, tc, namespace, moves)).To(Equal([]int64{1}), string(kind))
+
+			last := sc.newObj()
+			Expect(c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: artifactName(tc, tc.Status.ArtifactCount)}, last)).To(Succeed())
+			Expect(c.Delete(ctx, last)).To(Succeed())
+			Expect(sink.Stale(ctx, tc, namespace)).To(Equal([]int64{tc.Status.ArtifactCount}), string(kind))
+			stale, err := sink.Verify(ctx, tc, namespace, moves)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stale).To(Equal([]int64{1, tc.Status.ArtifactCount}), string(kind))
+
+			for _, i := range stale {
+				_, err := sink.Publish(ctx, tc, namespace, moves, (i-1)*tc.Status.MovesPerArtifact, 1, nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(sink.Stale(ctx, tc, namespace)).To(BeEmpty(), string(kind))
+			Expect(sink.Verify(ctx, tc, namespace, moves)).To(BeEmpty(), string(kind))
+		}
+	})
+
+	It("prunes the objects it controls that are not kept", func() {
+		ctx := context.Background()
+		for kind, sc := range cases {
+			c := newFakeClient()
+			sink := sc.newSink(c)
+			tc, _ := publish(c, sink)
+			count := tc.Status.ArtifactCount
+			foreign := sc.newObj()
+			foreign.SetName(artifactName(tc, count+1))
+			foreign.SetNamespace(namespace)
+			foreign.SetLabels(map[string]string{challengeLabel: tc.Name})
+			if move, ok := foreign.(*webappv1alpha1.TowerMove); ok {
+				move.Spec.Challenge = tc.Name
+			}
+			Expect(c.Create(ctx, foreign)).To(Succeed())
+
+			tc.Status.ArtifactCount = 1
+			Expect(sink.Prune(ctx, tc, namespace, func(name string) bool { return writtenArtifact(tc, name) })).To(Succeed(), string(kind))
+			Expect(c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: artifactName(tc, 1)}, sc.newObj())).To(Succeed(), string(kind))
+			err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: artifactName(tc, count)}, sc.newObj())
+			Expect(errors.IsNotFound(err)).To(BeTrue(), string(kind))
+
+			Expect(sink.Prune(ctx, tc, namespace, nil)).To(Succeed(), string(kind))
+			err = c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: artifactName(tc, 1)}, sc.newObj())
+			Expect(errors.IsNotFound(err)).To(BeTrue(), string(kind))
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(foreign), sc.newObj())).To(Succeed(), string(kind))
+		}
+	})
+
+	It("caches only the ConfigMaps and Secrets labelled for a challenge", func() {
+		opts := CacheOptions()
+		for _, obj := range []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}} {
+			var selector labels.Selector
+			for cached, byObject := range opts.ByObject {
+				if reflect.TypeOf(cached) == reflect.TypeOf(obj) {
+					selector = byObject.Label
+				}
+			}
+			Expect(selector).NotTo(BeNil())
+			Expect(selector.Matches(labels.Set{challengeLabel: "any"})).To(BeTrue())
+			Expect(selector.Matches(labels.Set{"app": "other"})).To(BeFalse())
+		}
+	})
+})
+
+var _ = Describe("TowerChallenge field ownership", func() {
+	It("takes back fields another manager changed", func() {
+		var forced []bool
+		c := interceptor.NewClient(newFakeClient(), interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				po := &client.PatchOptions{}
+				po.ApplyOptions(opts)
+				Expect(po.FieldManager).To(Equal(fieldManager))
+				forced = append(forced, po.Force != nil && *po.Force)
+				if len(forced) == 1 {
+					return errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, obj.GetName(),
+						fmt.Errorf(`conflict with "kubectl-edit": .data.move`))
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		})
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "drifted-move-1", Namespace: "tower-challenge"},
+			Data:       map[string]string{moveKey: "Move disk 1 from A to C"},
+		}
+		Expect(applyObject(context.Background(), c, cm, operationCreated)).To(Succeed())
+		Expect(forced).To(Equal([]bool{false, true}))
+	})
+})
+
+var _ = Describe("TowerChallenge deletion", func() {
+	const namespace = "tower-challenge"
+
+	deleteWithPolicy := func(policy xpv1.DeletionPolicy) (client.Client, *corev1.ConfigMap) {
+		now := metav1.Now()
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "deleted",
+				UID:               "deleted-uid",
+				Finalizers:        []string{challengeFinalizer},
+				DeletionTimestamp: &now,
+			},
+			Spec: webappv1alpha1.TowerChallengeSpec{Discs: 1, TargetNamespace: namespace, DeletionPolicy: policy},
+		}
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      "deleted-move-1",
+			Namespace: namespace,
+			Labels:    map[string]string{challengeLabel: tc.Name},
+		}}
+		c := newFakeClient(tc)
+		Expect(controllerutil.SetControllerReference(tc, cm, c.Scheme())).To(Succeed())
+		Expect(c.Create(context.Background(), cm)).To(Succeed())
+
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		_, err := r.Reconcile(context.Background(), reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: tc.Name},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		err = c.Get(context.Background(), types.NamespacedName{Name: tc.Name}, &webappv1alpha1.TowerChallenge{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		return c, cm
+	}
+
+	It("deletes the generated moves with the Delete policy", func() {
+		c, cm := deleteWithPolicy(xpv1.DeletionDelete)
+		err := c.Get(context.Background(), client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("releases the generated moves with the Orphan policy", func() {
+		c, cm := deleteWithPolicy(xpv1.DeletionOrphan)
+		kept := &corev1.ConfigMap{}
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(cm), kept)).To(Succeed())
+		Expect(metav1.GetControllerOf(kept)).To(BeNil())
+		Expect(kept.Labels).NotTo(HaveKey(challengeLabel))
+		Expect(kept.Annotations).To(HaveKeyWithValue(releasedAnnotation, "deleted"))
+	})
+
+	It("leaves released moves to a later challenge of the same name", func() {
+		ctx := context.Background()
+		c := newFakeClient(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "reused"}}
+		publish := func(discs int) {
+			tc := &webappv1alpha1.TowerChallenge{
+				ObjectMeta: metav1.ObjectMeta{Name: "reused", UID: types.UID(fmt.Sprintf("reused-%d", discs))},
+				Spec:       webappv1alpha1.TowerChallengeSpec{Discs: discs, TargetNamespace: namespace, DeletionPolicy: xpv1.DeletionOrphan},
+			}
+			Expect(c.Create(ctx, tc)).To(Succeed())
+			for i := 0; i < 5; i++ {
+				_, err := r.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+
+		publish(2)
+		tc := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(c.Delete(ctx, tc)).To(Succeed())
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(errors.IsNotFound(c.Get(ctx, req.NamespacedName, tc))).To(BeTrue())
+
+		publish(1)
+		for _, name := range []string{"reused-move-2", "reused-move-3"} {
+			released := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, released)).To(Succeed())
+			Expect(metav1.GetControllerOf(released)).To(BeNil())
+			Expect(released.Annotations).To(HaveKeyWithValue(releasedAnnotation, "reused"))
+		}
+	})
+})
+
+var _ = Describe("TowerChallenge conditions", func() {
+	reconcileChallenge := func(spec webappv1alpha1.TowerChallengeSpec) *webappv1alpha1.TowerChallenge {
+		tc := &webappv1alpha1.TowerChallenge{ObjectMeta: metav1.ObjectMeta{Name: "conditions"}, Spec: spec}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tower-challenge"}}
+		c := newFakeClient(tc, ns)
+
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		_, _ = r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}})
+		got := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(context.Background(), types.NamespacedName{Name: tc.Name}, got)).To(Succeed())
+		return got
+	}
+
+	It("reports Ready and Synced once every move is written", func() {
+		tc := reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 2, TargetNamespace: "tower-challenge"})
+		Expect(tc.GetCondition(xpv1.TypeReady).Reason).To(Equal(xpv1.ReasonAvailable))
+		Expect(tc.GetCondition(xpv1.TypeReady).Status).To(Equal(corev1.ConditionTrue))
+		Expect(tc.GetCondition(xpv1.TypeSynced).Status).To(Equal(corev1.ConditionTrue))
+		Expect(tc.Status.ConfigMapsCreated).To(BeTrue())
+		Expect(tc.Status.ConfigMapNames).To(BeEmpty())
+		Expect(tc.Status.Steps).To(Equal([]string{
+			"Move disk 1 from A to B",
+			"Move disk 2 from A to C",
+			"Move disk 1 from B to C",
+		}))
+		Expect(tc.Status.StepsDigest).To(HavePrefix("sha256:"))
+	})
+
+	It("reports a failed validation on Synced", func() {
+		tc := reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 2, TargetNamespace: "missing"})
+		Expect(tc.GetCondition(xpv1.TypeReady).Status).To(Equal(corev1.ConditionFalse))
+		synced := tc.GetCondition(xpv1.TypeSynced)
+		Expect(synced.Status).To(Equal(corev1.ConditionFalse))
+		Expect(synced.Reason).To(Equal(webappv1alpha1.ReasonValidationFailed))
+		Expect(synced.Message).To(ContainSubstring(`target namespace "missing" does not exist`))
+	})
+
+	It("rejects more discs than the controller solves without solving them", func() {
+		tc := reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 50, TargetNamespace: "tower-challenge"})
+		synced := tc.GetCondition(xpv1.TypeSynced)
+		Expect(synced.Reason).To(Equal(webappv1alpha1.ReasonValidationFailed))
+		Expect(synced.Message).To(ContainSubstring("must not exceed 20"))
+		Expect(tc.Status.TotalMoves).To(BeZero())
+	})
+
+	It("rejects variants whose solutions are too long to hold", func() {
+		tc := reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 20, Variant: webappv1alpha1.VariantAdjacent, TargetNamespace: "tower-challenge"})
+		synced := tc.GetCondition(xpv1.TypeSynced)
+		Expect(synced.Reason).To(Equal(webappv1alpha1.ReasonValidationFailed))
+		Expect(synced.Message).To(ContainSubstring("solving 20 discs can take 3486784400 moves"))
+	})
+
+	It("rejects more objects than the controller writes without solving them", func() {
+		tc := reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 17, TargetNamespace: "tower-challenge"})
+		synced := tc.GetCondition(xpv1.TypeSynced)
+		Expect(synced.Reason).To(Equal(webappv1alpha1.ReasonValidationFailed))
+		Expect(synced.Message).To(ContainSubstring("can take 131071 objects, more than the limit of 65535"))
+		Expect(tc.Status.TotalMoves).To(BeZero())
+	})
+
+	It("counts the chunks against the object ceiling once they are laid out", func() {
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "conditions"},
+			Spec: webappv1alpha1.TowerChallengeSpec{
+				Discs:           8,
+				TargetNamespace: "tower-challenge",
+				Output:          webappv1alpha1.Output{Kind: webappv1alpha1.OutputChunkedConfigMap, MaxChunkBytes: 4096},
+			},
+		}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tower-challenge"}}
+		c := newFakeClient(tc, ns)
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100), MaxObjects: 2}
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}})
+		Expect(err).NotTo(HaveOccurred())
+
+		got := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(context.Background(), types.NamespacedName{Name: tc.Name}, got)).To(Succeed())
+		Expect(got.GetCondition(xpv1.TypeSynced).Message).To(MatchRegexp(`the moves take \d+ objects of \d+ moves each, more than the limit of 2`))
+		var written corev1.ConfigMapList
+		Expect(c.List(context.Background(), &written, client.InNamespace("tower-challenge"))).To(Succeed())
+		Expect(written.Items).To(BeEmpty())
+	})
+
+	It("rejects more pegs than are supported", func() {
+		tc := reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 3, PegCount: 17, TargetNamespace: "tower-challenge"})
+		Expect(tc.GetCondition(xpv1.TypeSynced).Message).To(ContainSubstring("at most 16 pegs"))
+	})
+
+	It("inspects a move and counts the moves before solving", func() {
+		tc := reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 3, InspectMove: 9, TargetNamespace: "tower-challenge"})
+		Expect(tc.GetCondition(xpv1.TypeSynced).Message).To(ContainSubstring("inspectMove must be between 1 and 7"))
+		Expect(tc.GetCondition(xpv1.TypeReady).Reason).NotTo(Equal(webappv1alpha1.ReasonSolving))
+
+		tc = reconcileChallenge(webappv1alpha1.TowerChallengeSpec{Discs: 3, InspectMove: 4, TargetNamespace: "tower-challenge"})
+		Expect(tc.Status.TotalMoves).To(Equal(int64(7)))
+		Expect(tc.Status.InspectedMove.Disc).To(Equal(3))
+	})
+})
+
+var _ = Describe("TowerChallenge resyncs", func() {
+	const namespace = "tower-challenge"
+	var (
+		ctx context.Context
+		c   client.Client
+		r   *TowerChallengeReconciler
+		req reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "resynced", Generation: 1},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2, TargetNamespace: namespace},
+		}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		c = newFakeClient(tc, ns)
+		r = &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}}
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("records the generation and spec it wrote moves for", func() {
+		tc := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(tc.Status.ObservedGeneration).To(Equal(int64(1)))
+		hash, err := specHash(tc.Spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tc.Status.SpecHash).To(Equal(hash))
+	})
+
+	It("leaves everything alone when nothing changed", func() {
+		before := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, before)).To(Succeed())
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		after := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, after)).To(Succeed())
+		Expect(after.ResourceVersion).To(Equal(before.ResourceVersion))
+	})
+
+	It("does not rewrite the moves when only the deletion policy changes", func() {
+		tc := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: "resynced-move-1", Namespace: namespace}
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+
+		tc.Spec.DeletionPolicy = xpv1.DeletionOrphan
+		tc.Generation = 2
+		Expect(c.Update(ctx, tc)).To(Succeed())
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		after := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, key, after)).To(Succeed())
+		Expect(after.ResourceVersion).To(Equal(cm.ResourceVersion))
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(tc.Status.ObservedGeneration).To(Equal(int64(2)))
+	})
+
+	It("writes the moves again when one goes missing", func() {
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: "resynced-move-2", Namespace: namespace}
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		Expect(c.Delete(ctx, cm)).To(Succeed())
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+	})
+
+	It("records the digests of the solution and of every move", func() {
+		tc := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(tc.Status.SolutionDigest).To(HavePrefix("sha256:"))
+		for i := int64(1); i <= tc.Status.ArtifactCount; i++ {
+			cm := &corev1.ConfigMap{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: artifactName(tc, i), Namespace: namespace}, cm)).To(Succeed())
+			Expect(cm.Annotations).To(HaveKeyWithValue(solutionDigestAnnotation, tc.Status.SolutionDigest))
+			digest, _, err := objectDigest(cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Annotations).To(HaveKeyWithValue(dataDigestAnnotation, digest))
+		}
+	})
+
+	It("repairs a move whose data was changed", func() {
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: "resynced-move-2", Namespace: namespace}
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		want := cm.Data[moveKey]
+		cm.Data[moveKey] = "Move disk 2 from A to B"
+		Expect(c.Update(ctx, cm)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue(moveKey, want))
+	})
+
+	It("repairs a move whose data was changed along with its digest once it is verified", func() {
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: "resynced-move-2", Namespace: namespace}
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		want := cm.Data[moveKey]
+		cm.Data[moveKey] = "Move disk 2 from A to B"
+		digest, _, err := objectDigest(cm)
+		Expect(err).NotTo(HaveOccurred())
+		cm.Annotations[dataDigestAnnotation] = digest
+		Expect(c.Update(ctx, cm)).To(Succeed())
+
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue(moveKey, "Move disk 2 from A to B"))
+
+		r.VerifyPeriod = time.Nanosecond
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue(moveKey, want))
+		Expect(cm.Annotations).NotTo(HaveKeyWithValue(dataDigestAnnotation, digest))
+	})
+
+	It("checks the objects against their digests until a verification is due", func() {
+		verifying := &verifyCountingSink{MoveSink: newMoveSinks(c, r.Recorder)[webappv1alpha1.OutputConfigMap]}
+		r.Sinks = map[webappv1alpha1.OutputKind]MoveSink{webappv1alpha1.OutputConfigMap: verifying}
+		before := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, before)).To(Succeed())
+		Expect(before.Status.VerifiedTime.IsZero()).To(BeFalse())
+
+		result, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(result.RequeueAfter).To(BeNumerically("<=", defaultVerifyPeriod))
+		Expect(verifying.verified).To(BeZero())
+
+		r.VerifyPeriod = time.Nanosecond
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifying.verified).To(Equal(1))
+		after := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, after)).To(Succeed())
+		Expect(after.Status.VerifiedTime.Before(&before.Status.VerifiedTime)).To(BeFalse())
+		Expect(after.ResourceVersion).NotTo(Equal(before.ResourceVersion))
+	})
+
+	It("rewrites only the objects that are stale", func() {
+		untouched := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "resynced-move-1", Namespace: namespace}, untouched)).To(Succeed())
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: "resynced-move-3", Namespace: namespace}
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		Expect(c.Delete(ctx, cm)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, cm)).To(Succeed())
+		after := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(untouched), after)).To(Succeed())
+		Expect(after.ResourceVersion).To(Equal(untouched.ResourceVersion))
+		tc := &webappv1alpha1.TowerChallenge{}
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(tc.Status.Phase).To(Equal("Completed"))
+	})
+})
+
+var _ = Describe("TowerChallenge publication", func() {
+	const namespace = "tower-challenge"
+
+	It("writes moves in batches and resumes from the cursor", func() {
+		ctx := context.Background()
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "batched"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 3, TargetNamespace: namespace},
+		}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		c := newFakeClient(tc, ns)
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100), PublishBatchSize: 3}
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}}
+
+		var published []int64
+		for i := 0; i < 3; i++ {
+			result, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+			published = append(published, tc.Status.PublishedMoves)
+			if tc.Status.PublishedMoves < 7 {
+				Expect(result.RequeueAfter).To(Equal(publishRequeueDelay))
+			} else {
+				Expect(result.RequeueAfter).To(Equal(defaultVerifyPeriod))
+			}
+		}
+		Expect(published).To(Equal([]int64{3, 6, 7}))
+		Expect(tc.Status.Progress).To(Equal("100%"))
+		Expect(tc.Status.Phase).To(Equal("Completed"))
+		Expect(tc.Status.ArtifactPrefix).To(Equal("batched-move-"))
+		Expect(tc.Status.MovesPerArtifact).To(Equal(int64(1)))
+		Expect(tc.Status.ArtifactCount).To(Equal(int64(7)))
+	})
+
+	It("drops the list of ConfigMap names left by earlier versions", func() {
+		ctx := context.Background()
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "listed"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2, TargetNamespace: namespace},
+			Status:     webappv1alpha1.TowerChallengeStatus{ConfigMapNames: []string{"listed-move-1", "listed-move-2", "listed-move-3"}},
+		}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		c := newFakeClient(tc, ns)
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(tc.Status.Phase).To(Equal("Completed"))
+		Expect(tc.Status.ConfigMapNames).To(BeEmpty())
+		Expect(tc.Status.ArtifactCount).To(Equal(int64(3)))
+	})
+
+	It("records the layout of the chunks instead of their names", func() {
+		ctx := context.Background()
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "laid-out"},
+			Spec: webappv1alpha1.TowerChallengeSpec{
+				Discs:           6,
+				TargetNamespace: namespace,
+				Output:          webappv1alpha1.Output{Kind: webappv1alpha1.OutputChunkedConfigMap, MaxChunkBytes: 4096},
+			},
+		}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		c := newFakeClient(tc, ns)
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100), PublishBatchSize: 2}
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}}
+
+		for tc.Status.Phase != "Completed" {
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		}
+		status := tc.Status
+		Expect(status.ArtifactPrefix).To(Equal("laid-out-moves-"))
+		Expect(status.MovesPerArtifact).To(BeNumerically(">", 1))
+		Expect(status.ArtifactCount).To(Equal((status.TotalMoves + status.MovesPerArtifact - 1) / status.MovesPerArtifact))
+
+		var cms corev1.ConfigMapList
+		Expect(c.List(ctx, &cms, client.InNamespace(namespace))).To(Succeed())
+		Expect(cms.Items).To(HaveLen(int(status.ArtifactCount)))
+		last := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: artifactName(tc, status.ArtifactCount)}, last)).To(Succeed())
+		Expect(last.Data).To(HaveKey(fmt.Sprintf("move-%d", status.TotalMoves)))
+	})
+
+	It("keeps chunk boundaries whatever the batch", func() {
+		spec := webappv1alpha1.TowerChallengeSpec{Discs: 6}
+		b, err := newBoard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		start, goal, err := b.resolveStates(spec)
+		Expect(err).NotTo(HaveOccurred())
+		sol, err := solveChallenge(spec, b, start, goal)
+		Expect(err).NotTo(HaveOccurred())
+		moves := newMoveSource(b, sol)
+
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "chunked", UID: "chunked-uid"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Output: webappv1alpha1.Output{MaxChunkBytes: 4096}},
+		}
+		sink := &chunkedConfigMapSink{Client: newFakeClient()}
+
+		all, err := sink.Publish(context.Background(), tc, namespace, moves, 0, 0, nil)
+		Expect(err).NotTo(HaveOccurred())
+		var batched []webappv1alpha1.MoveChunk
+		for after := int64(0); after < moves.Total(); {
+			chunks, err := sink.Publish(context.Background(), tc, namespace, moves, after, 1, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chunks).To(HaveLen(1))
+			batched = append(batched, chunks...)
+			after = chunks[0].LastMove
+		}
+		Expect(batched).To(Equal(all))
+	})
+})
+
+var _ = Describe("TowerChallenge write failures", func() {
+	const namespace = "tower-challenge"
+
+	It("lists the missing moves and retries until they are written", func() {
+		ctx := context.Background()
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "degraded"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2, TargetNamespace: namespace},
+		}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		failing := true
+		c := interceptor.NewClient(newFakeClient(tc, ns), interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if failing && obj.GetName() == "degraded-move-2" {
+					return errors.NewServiceUnavailable("etcd is busy")
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		})
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).To(MatchError(ContainSubstring("failed to write degraded-move-2")))
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(tc.Status.Phase).To(Equal("Degraded"))
+		Expect(tc.Status.MissingMoves).To(Equal([]webappv1alpha1.MoveChunk{{Name: "degraded-move-2", FirstMove: 2, LastMove: 2}}))
+		Expect(tc.Status.PublishedMoves).To(Equal(int64(1)))
+		Expect(tc.GetCondition(xpv1.TypeSynced).Reason).To(Equal(xpv1.ReasonReconcileError))
+
+		failing = false
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(tc.Status.Phase).To(Equal("Completed"))
+		Expect(tc.Status.MissingMoves).To(BeEmpty())
+		Expect(tc.Status.ArtifactCount).To(Equal(int64(3)))
+		Expect(writtenArtifact(tc, "degraded-move-3")).To(BeTrue())
+		Expect(writtenArtifact(tc, "degraded-move-4")).To(BeFalse())
+		Expect(writtenArtifact(tc, "degraded-move-03")).To(BeFalse())
+	})
+})
+
+var _ = Describe("TowerChallenge metrics", func() {
+	const namespace = "tower-challenge"
+
+	It("counts the objects written, deleted and failed", func() {
+		ctx := context.Background()
+		tc := &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "measured"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2, TargetNamespace: namespace},
+		}
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		failing := false
+		c := interceptor.NewClient(newFakeClient(tc, ns), interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if failing && obj.GetName() == "measured-move-1" {
+					return errors.NewServiceUnavailable("etcd is busy")
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		})
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100)}
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}}
+		count := func(m *prometheus.CounterVec, operation string) float64 {
+			return testutil.ToFloat64(m.WithLabelValues("ConfigMap", operation))
+		}
+		created, updated := count(artifactOperations, operationCreated), count(artifactOperations, operationUpdated)
+		deleted, failed := count(artifactOperations, operationDeleted), count(artifactFailures, operationApply)
+
+		// The first move fails, so the cursor stays put while the others are
+		// created.
+		failing = true
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).To(HaveOccurred())
+		Expect(count(artifactOperations, operationCreated) - created).To(Equal(2.0))
+		Expect(count(artifactFailures, operationApply) - failed).To(Equal(1.0))
+
+		// The retry creates the missing move and overwrites the two others.
+		failing = false
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count(artifactOperations, operationCreated) - created).To(Equal(3.0))
+		Expect(count(artifactOperations, operationUpdated) - updated).To(Equal(2.0))
+		Expect(testutil.ToFloat64(outstandingMoves.WithLabelValues(tc.Name))).To(Equal(0.0))
+		Expect(testutil.CollectAndCount(solveDuration)).To(BeNumerically(">=", 1))
+
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "measured-move-1", Namespace: namespace}, cm)).To(Succeed())
+		Expect(c.Delete(ctx, cm)).To(Succeed())
+		failing = true
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).To(HaveOccurred())
+		Expect(count(artifactFailures, operationApply) - failed).To(Equal(2.0))
+
+		// Repairing a published move updates it.
+		failing = false
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count(artifactOperations, operationCreated) - created).To(Equal(3.0))
+		Expect(count(artifactOperations, operationUpdated) - updated).To(Equal(3.0))
+		Expect(c.Get(ctx, req.NamespacedName, tc)).To(Succeed())
+		Expect(c.Delete(ctx, tc)).To(Succeed())
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count(artifactOperations, operationDeleted) - deleted).To(Equal(3.0))
+		Expect(outstandingMoves.DeleteLabelValues(tc.Name)).To(BeFalse())
+	})
+})
+
+var _ = Describe("TowerChallenge events", func() {
+	const namespace = "tower-challenge"
+	var (
+		ctx      context.Context
+		tc       *webappv1alpha1.TowerChallenge
+		ns       *corev1.Namespace
+		recorder *record.FakeRecorder
+		req      reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tc = &webappv1alpha1.TowerChallenge{
+			ObjectMeta: metav1.ObjectMeta{Name: "evented", UID: "evented-uid"},
+			Spec:       webappv1alpha1.TowerChallengeSpec{Discs: 2, TargetNamespace: namespace},
+		}
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		recorder = record.NewFakeRecorder(100)
+		req = reconcile.Request{NamespacedName: types.NamespacedName{Name: tc.Name}}
+	})
+
+	events := func() []string {
+		var got []string
+		for len(recorder.Events) > 0 {
+			got = append(got, <-recorder.Events)
+		}
+		return got
+	}
+
+	It("reports the solve and the pruned moves", func() {
+		stale := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      "evented-move-9",
+			Namespace: namespace,
+			Labels:    map[string]string{challengeLabel: tc.Name},
+		}}
+		c := newFakeClient(tc, ns)
+		Expect(controllerutil.SetControllerReference(tc, stale, c.Scheme())).To(Succeed())
+		Expect(c.Create(ctx, stale)).To(Succeed())
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events()).To(ConsistOf(
+			HavePrefix("Normal Solved Solved 2 discs in 3 moves in "),
+			"Normal Pruned Deleted 1 old ConfigMap objects from namespace tower-challenge",
+		))
+	})
+
+	It("reports validation failures with the reason of the condition", func() {
+		tc.Spec.TargetNamespace = "missing"
+		c := newFakeClient(tc, ns)
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+		result, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(missingNamespaceRetry))
+		Expect(events()).To(ContainElement(`Warning ValidationFailed target namespace "missing" does not exist`))
+	})
+
+	It("does not retry a spec that cannot be solved", func() {
+		tc.Spec.Discs = 50
+		c := newFakeClient(tc, ns)
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+		result, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(reconcile.Result{}))
+		Expect(events()).To(ConsistOf(HavePrefix("Warning ValidationFailed the number of discs must not exceed")))
+	})
+
+	It("returns the error when the failure cannot be recorded", func() {
+		tc.Spec.Discs = 50
+		c := interceptor.NewClient(newFakeClient(tc, ns), interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				return errors.NewServiceUnavailable("etcd is busy")
+			},
+		})
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(errors.IsServiceUnavailable(err)).To(BeTrue())
+	})
+
+	It("reports moves that could not be written", func() {
+		c := interceptor.NewClient(newFakeClient(tc, ns), interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if obj.GetName() == "evented-move-3" {
+					return errors.NewServiceUnavailable("etcd is busy")
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		})
+		r := &TowerChallengeReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).To(HaveOccurred())
+		Expect(events()).To(ContainElement(HavePrefix("Warning WriteFailed failed to write evented-move-3")))
+	})
+})
@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/solver"
+)
+
+// ErrSnapshotMismatch is returned when a move record describes pegs that
+// differ from the board once its move has been played.
+var ErrSnapshotMismatch = errors.New("the record does not match the board")
+
+// RulesFor returns the rules of variant on three pegs, numbered in the order
+// they are listed.
+func RulesFor(variant webappv1alpha1.Variant) (Rules, error) {
+	switch variant {
+	case "", webappv1alpha1.VariantClassic:
+		return Rules{}, nil
+	case webappv1alpha1.VariantCyclic:
+		return Rules{Allowed: Cyclic(0, 1, 2)}, nil
+	case webappv1alpha1.VariantAdjacent:
+		return Rules{Allowed: Adjacent(0, 1, 2)}, nil
+	case webappv1alpha1.VariantBicolor:
+		return Rules{AllowEqual: true}, nil
+	default:
+		return Rules{}, fmt.Errorf("unknown variant %q", variant)
+	}
+}
+
+// FromPegStates returns a board with one peg for every name, in order,
+// holding the discs states list for it. Pegs left out of states are empty.
+func FromPegStates(names []string, states []webappv1alpha1.PegState, rules Rules) (*Board, error) {
+	pegs := make([][]int, len(names))
+	for _, state := range states {
+		i := indexOf(names, state.Name)
+		if i < 0 {
+			return nil, fmt.Errorf("peg %q: %w", state.Name, ErrNoSuchPeg)
+		}
+		pegs[i] = append(pegs[i], state.Discs...)
+	}
+	b, err := New(pegs, rules)
+	if err != nil {
+		return nil, err
+	}
+	b.names = append([]string(nil), names...)
+	return b, nil
+}
+
+// PegStates returns the discs on each peg as named stacks. Pegs of boards
+// not built by FromPegStates are named by their number.
+func (b *Board) PegStates() []webappv1alpha1.PegState {
+	states := make([]webappv1alpha1.PegState, len(b.pegs))
+	for i, stack := range b.pegs {
+		states[i] = webappv1alpha1.PegState{Name: b.name(i)}
+		if len(stack) > 0 {
+			states[i].Discs = append([]int(nil), stack...)
+		}
+	}
+	return states
+}
+
+// PlayRecord plays the move rec describes, by peg name, and checks that rec
+// is the next move of the game and describes the board once it is played.
+// The board is left unchanged when the move is illegal, and is not rolled
+// back when only the description is wrong.
+func (b *Board) PlayRecord(rec webappv1alpha1.MoveRecord) error {
+	m := solver.Move{Disk: rec.Disc, From: solver.Peg(b.index(rec.From)), To: solver.Peg(b.index(rec.To))}
+	if rec.Index != b.played+1 {
+		return &IllegalMoveError{Index: b.played + 1, Move: m, Err: fmt.Errorf("the record is for move %d", rec.Index)}
+	}
+	for _, name := range []string{rec.From, rec.To} {
+		if b.index(name) < 0 {
+			return &IllegalMoveError{Index: b.played + 1, Move: m, Err: fmt.Errorf("peg %q: %w", name, ErrNoSuchPeg)}
+		}
+	}
+	if err := b.Play(m); err != nil {
+		return err
+	}
+	if rec.Pegs == nil {
+		return nil
+	}
+	got := b.PegStates()
+	if len(rec.Pegs) != len(got) {
+		return fmt.Errorf("move %d: %w: %d pegs instead of %d", rec.Index, ErrSnapshotMismatch, len(rec.Pegs), len(got))
+	}
+	for i, peg := range rec.Pegs {
+		if peg.Name != got[i].Name || !equalStacks(peg.Discs, got[i].Discs) {
+			return fmt.Errorf("move %d: %w: peg %s holds %v instead of %v", rec.Index, ErrSnapshotMismatch, got[i].Name, peg.Discs, got[i].Discs)
+		}
+	}
+	return nil
+}
+
+// name is the name of peg i.
+func (b *Board) name(i int) string {
+	if i < len(b.names) {
+		return b.names[i]
+	}
+	return strconv.Itoa(i)
+}
+
+// index returns the peg with the given name, or -1 if there is none.
+func (b *Board) index(name string) int {
+	for i := range b.pegs {
+		if b.name(i) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalStacks(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator replays Tower of Hanoi moves on a board of any number of
+// discs and pegs and rejects the illegal ones. It shares no code with the
+// solvers, so it can check their output as well as moves played by hand.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+
+	"hanoi.com/towerofhanoi/pkg/solver"
+)
+
+// Reasons a move is illegal, wrapped by IllegalMoveError.
+var (
+	ErrNoSuchPeg       = errors.New("no such peg")
+	ErrSamePeg         = errors.New("a disc must move to another peg")
+	ErrEmptyPeg        = errors.New("the peg is empty")
+	ErrWrongDisc       = errors.New("the disc is not on top of the peg")
+	ErrLargerOnSmaller = errors.New("a disc cannot rest on a smaller disc")
+	ErrNotAllowed      = errors.New("the rules do not allow moving between these pegs")
+)
+
+// IllegalMoveError reports a move that breaks the rules of the board.
+type IllegalMoveError struct {
+	// Index is the position of the move in the game, starting at 1.
+	Index int64
+	Move  solver.Move
+	Err   error
+}
+
+func (e *IllegalMoveError) Error() string {
+	return fmt.Sprintf("move %d (%s) is illegal: %v", e.Index, e.Move, e.Err)
+}
+
+func (e *IllegalMoveError) Unwrap() error {
+	return e.Err
+}
+
+// Rules adds to the basic rules, under which only the top disc of a peg
+// moves and never onto a smaller disc.
+type Rules struct {
+	// AllowEqual lets a disc rest on another disc of the same size, as in the
+	// bicolor tower.
+	AllowEqual bool
+	// Allowed reports whether a disc may travel directly from one peg to
+	// another. Nil allows every pair of pegs.
+	Allowed func(from, to solver.Peg) bool
+}
+
+// Board holds the discs on each peg, listed from the bottom up, and counts
+// the moves played on it.
+type Board struct {
+	pegs   [][]int
+	names  []string
+	rules  Rules
+	played int64
+}
+
+// New returns a board with the given stacks, listed from the bottom up. At
+// least three pegs are needed and no disc may rest on a smaller one.
+func New(pegs [][]int, rules Rules) (*Board, error) {
+	if len(pegs) < 3 {
+		return nil, fmt.Errorf("a board needs at least 3 pegs, got %d", len(pegs))
+	}
+	b := &Board{pegs: make([][]int, len(pegs)), rules: rules}
+	for i, stack := range pegs {
+		for j, disc := range stack {
+			if disc < 1 {
+				return nil, fmt.Errorf("peg %d holds disc %d, discs are numbered from 1", i, disc)
+			}
+			if j > 0 && !b.fits(disc, stack[j-1]) {
+				return nil, fmt.Errorf("peg %d: disc %d on disc %d: %w", i, disc, stack[j-1], ErrLargerOnSmaller)
+			}
+		}
+		b.pegs[i] = append([]int(nil), stack...)
+	}
+	return b, nil
+}
+
+// NewTower returns a board of pegCount pegs with discs discs stacked on peg.
+func NewTower(discs, pegCount int, peg solver.Peg, rules Rules) (*Board, error) {
+	if peg < 0 || int(peg) >= pegCount {
+		return nil, fmt.Errorf("peg %d: %w", peg, ErrNoSuchPeg)
+	}
+	pegs := make([][]int, pegCount)
+	for disc := discs; disc >= 1; disc-- {
+		pegs[peg] = append(pegs[peg], disc)
+	}
+	return New(pegs, rules)
+}
+
+// fits reports whether disc may rest on below.
+func (b *Board) fits(disc, below int) bool {
+	return disc < below || (disc == below && b.rules.AllowEqual)
+}
+
+// Play moves the top disc of m.From onto m.To. m.Disk must name that disc,
+// unless it is zero. An illegal move returns an *IllegalMoveError and
+// leaves the board unchanged.
+func (b *Board) Play(m solver.Move) error {
+	if err := b.check(m); err != nil {
+		return &IllegalMoveError{Index: b.played + 1, Move: m, Err: err}
+	}
+	from := b.pegs[m.From]
+	disc := from[len(from)-1]
+	b.pegs[m.From] = from[:len(from)-1]
+	b.pegs[m.To] = append(b.pegs[m.To], disc)
+	b.played++
+	return nil
+}
+
+func (b *Board) check(m solver.Move) error {
+	for _, p := range []solver.Peg{m.From, m.To} {
+		if p < 0 || int(p) >= len(b.pegs) {
+			return fmt.Errorf("peg %d: %w", p, ErrNoSuchPeg)
+		}
+	}
+	if m.From == m.To {
+		return ErrSamePeg
+	}
+	if b.rules.Allowed != nil && !b.rules.Allowed(m.From, m.To) {
+		return ErrNotAllowed
+	}
+	from := b.pegs[m.From]
+	if len(from) == 0 {
+		return fmt.Errorf("peg %s: %w", b.name(int(m.From)), ErrEmptyPeg)
+	}
+	disc := from[len(from)-1]
+	if m.Disk != 0 && m.Disk != disc {
+		return fmt.Errorf("disc %d is on top of peg %s: %w", disc, b.name(int(m.From)), ErrWrongDisc)
+	}
+	if to := b.pegs[m.To]; len(to) > 0 && !b.fits(disc, to[len(to)-1]) {
+		return fmt.Errorf("disc %d on disc %d: %w", disc, to[len(to)-1], ErrLargerOnSmaller)
+	}
+	return nil
+}
+
+// Replay plays moves in order, stopping at the first illegal one.
+func (b *Board) Replay(moves []solver.Move) error {
+	for _, m := range moves {
+		if err := b.Play(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Played returns the number of moves played on the board.
+func (b *Board) Played() int64 {
+	return b.played
+}
+
+// Pegs returns the discs on each peg, listed from the bottom up.
+func (b *Board) Pegs() [][]int {
+	pegs := make([][]int, len(b.pegs))
+	for i, stack := range b.pegs {
+		pegs[i] = append([]int(nil), stack...)
+	}
+	return pegs
+}
+
+// Matches reports whether the board holds exactly the given stacks, listed
+// from the bottom up.
+func (b *Board) Matches(pegs [][]int) bool {
+	if len(pegs) != len(b.pegs) {
+		return false
+	}
+	for i, stack := range pegs {
+		if len(stack) != len(b.pegs[i]) {
+			return false
+		}
+		for j, disc := range stack {
+			if b.pegs[i][j] != disc {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Cyclic only allows discs to travel one way round the pegs listed in order,
+// from each peg to the next and from the last back to the first.
+func Cyclic(order ...solver.Peg) func(from, to solver.Peg) bool {
+	return func(from, to solver.Peg) bool {
+		for i, p := range order {
+			if p == from {
+				return order[(i+1)%len(order)] == to
+			}
+		}
+		return false
+	}
+}
+
+// Adjacent only allows discs to travel between neighbouring pegs of order.
+func Adjacent(order ...solver.Peg) func(from, to solver.Peg) bool {
+	return func(from, to solver.Peg) bool {
+		for i := 1; i < len(order); i++ {
+			if (order[i-1] == from && order[i] == to) || (order[i-1] == to && order[i] == from) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	webappv1alpha1 "hanoi.com/towerofhanoi/api/v1alpha1"
+	"hanoi.com/towerofhanoi/pkg/solver"
+)
+
+var _ = Describe("Board", func() {
+	It("replays a solution to the goal", func() {
+		b, err := NewTower(5, 3, 0, Rules{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Replay(solver.Solve(5, 0, 2, 1))).To(Succeed())
+		Expect(b.Played()).To(Equal(int64(31)))
+		Expect(b.Matches([][]int{nil, {}, {5, 4, 3, 2, 1}})).To(BeTrue())
+	})
+
+	DescribeTable("rejects illegal moves",
+		func(m solver.Move, want error) {
+			b, err := New([][]int{{3, 1}, {2}, nil}, Rules{})
+			Expect(err).NotTo(HaveOccurred())
+			before := b.Pegs()
+
+			err = b.Play(m)
+			Expect(err).To(MatchError(want))
+			var illegal *IllegalMoveError
+			Expect(err).To(BeAssignableToTypeOf(illegal))
+			Expect(b.Pegs()).To(Equal(before))
+			Expect(b.Played()).To(BeZero())
+		},
+		Entry("from an empty peg", solver.Move{Disk: 1, From: 2, To: 0}, ErrEmptyPeg),
+		Entry("a larger disc onto a smaller one", solver.Move{Disk: 2, From: 1, To: 0}, ErrLargerOnSmaller),
+		Entry("a disc that is not on top", solver.Move{Disk: 3, From: 0, To: 2}, ErrWrongDisc),
+		Entry("onto the same peg", solver.Move{Disk: 1, From: 0, To: 0}, ErrSamePeg),
+		Entry("to a peg that does not exist", solver.Move{Disk: 1, From: 0, To: 3}, ErrNoSuchPeg),
+	)
+
+	It("reports the index of the first illegal move", func() {
+		b, err := NewTower(2, 3, 0, Rules{})
+		Expect(err).NotTo(HaveOccurred())
+		err = b.Replay([]solver.Move{{Disk: 1, From: 0, To: 1}, {Disk: 2, From: 0, To: 1}})
+		var illegal *IllegalMoveError
+		Expect(err).To(BeAssignableToTypeOf(illegal))
+		Expect(err.(*IllegalMoveError).Index).To(Equal(int64(2)))
+		Expect(err).To(MatchError(ContainSubstring("move 2 (disk 2: 0 -> 1) is illegal")))
+	})
+
+	It("rejects boards with a disc on a smaller one", func() {
+		_, err := New([][]int{{1, 2}, nil, nil}, Rules{})
+		Expect(err).To(MatchError(ErrLargerOnSmaller))
+		_, err = New([][]int{{2, 2}, nil, nil}, Rules{})
+		Expect(err).To(MatchError(ErrLargerOnSmaller))
+		_, err = New([][]int{{2, 2}, nil, nil}, Rules{AllowEqual: true})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("restricts the pegs discs travel between", func() {
+		cyclic := Cyclic(0, 1, 2)
+		Expect(cyclic(0, 1)).To(BeTrue())
+		Expect(cyclic(2, 0)).To(BeTrue())
+		Expect(cyclic(1, 0)).To(BeFalse())
+		adjacent := Adjacent(0, 1, 2)
+		Expect(adjacent(1, 0)).To(BeTrue())
+		Expect(adjacent(0, 2)).To(BeFalse())
+
+		b, err := NewTower(3, 3, 0, Rules{Allowed: cyclic})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Play(solver.Move{Disk: 1, From: 0, To: 2})).To(MatchError(ErrNotAllowed))
+		Expect(b.Replay(solver.SolveCyclic(3, [3]solver.Peg{0, 1, 2}, 0, 2))).To(Succeed())
+		Expect(b.Matches([][]int{nil, nil, {3, 2, 1}})).To(BeTrue())
+	})
+})
+
+var _ = Describe("Move records", func() {
+	names := []string{"left", "middle", "right"}
+
+	It("plays moves by peg name and checks the snapshot", func() {
+		b, err := FromPegStates(names, []webappv1alpha1.PegState{{Name: "left", Discs: []int{2, 1}}}, Rules{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.PlayRecord(webappv1alpha1.MoveRecord{MoveSnapshot: webappv1alpha1.MoveSnapshot{
+			Index: 1, Disc: 1, From: "left", To: "middle",
+			Pegs: []webappv1alpha1.PegState{{Name: "left", Discs: []int{2}}, {Name: "middle", Discs: []int{1}}, {Name: "right"}},
+		}})).To(Succeed())
+
+		err = b.PlayRecord(webappv1alpha1.MoveRecord{MoveSnapshot: webappv1alpha1.MoveSnapshot{
+			Index: 2, Disc: 2, From: "left", To: "right",
+			Pegs: []webappv1alpha1.PegState{{Name: "left"}, {Name: "middle", Discs: []int{1}}, {Name: "right", Discs: []int{1}}},
+		}})
+		Expect(err).To(MatchError(ErrSnapshotMismatch))
+		Expect(b.PegStates()).To(Equal([]webappv1alpha1.PegState{
+			{Name: "left"}, {Name: "middle", Discs: []int{1}}, {Name: "right", Discs: []int{2}},
+		}))
+	})
+
+	It("rejects records out of order and unknown pegs", func() {
+		b, err := FromPegStates(names, []webappv1alpha1.PegState{{Name: "left", Discs: []int{1}}}, Rules{})
+		Expect(err).NotTo(HaveOccurred())
+		err = b.PlayRecord(webappv1alpha1.MoveRecord{MoveSnapshot: webappv1alpha1.MoveSnapshot{Index: 2, Disc: 1, From: "left", To: "right"}})
+		Expect(err).To(MatchError(ContainSubstring("the record is for move 2")))
+		err = b.PlayRecord(webappv1alpha1.MoveRecord{MoveSnapshot: webappv1alpha1.MoveSnapshot{Index: 1, Disc: 1, From: "left", To: "nowhere"}})
+		Expect(err).To(MatchError(ErrNoSuchPeg))
+
+		_, err = FromPegStates(names, []webappv1alpha1.PegState{{Name: "nowhere", Discs: []int{1}}}, Rules{})
+		Expect(err).To(MatchError(ErrNoSuchPeg))
+	})
+
+	It("knows the rules of every variant", func() {
+		rules, err := RulesFor(webappv1alpha1.VariantBicolor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.AllowEqual).To(BeTrue())
+		rules, err = RulesFor(webappv1alpha1.VariantAdjacent)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.Allowed(0, 2)).To(BeFalse())
+		_, err = RulesFor("spiral")
+		Expect(err).To(MatchError(ContainSubstring(`unknown variant "spiral"`)))
+	})
+})
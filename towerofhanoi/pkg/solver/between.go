@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package solver
+
+import "fmt"
+
+// SolveBetween returns an optimal sequence of moves that turns the start
+// configuration into the goal configuration on three pegs. Configurations are
+// indexed by disc, so element i holds the peg of disc i+1; any such assignment
+// is a legal position because each peg keeps its discs sorted by size.
+//
+// Only the largest disc that is out of place needs to move, and an optimal
+// solution moves it either once (directly to its goal peg) or twice (via the
+// third peg); both candidates are costed and the cheaper one is returned.
+func SolveBetween(start, goal []Peg) ([]Move, error) {
+	if err := checkBetween(start, goal); err != nil {
+		return nil, err
+	}
+
+	disk := len(start)
+	for disk > 0 && start[disk-1] == goal[disk-1] {
+		disk--
+	}
+	if disk == 0 {
+		return nil, nil
+	}
+
+	p, q := start[disk-1], goal[disk-1]
+	r := other(p, q)
+	once := gatherCost(start, disk-1, r) + 1 + gatherCost(goal, disk-1, r)
+	twice := gatherCost(start, disk-1, q) + MoveCount(disk-1) + 2 + gatherCost(goal, disk-1, p)
+
+	var moves []Move
+	if once <= twice {
+		moves = gather(moves, start, disk-1, r)
+		moves = append(moves, Move{Disk: disk, From: p, To: q})
+		moves = append(moves, scatter(goal, disk-1, r)...)
+	} else {
+		moves = gather(moves, start, disk-1, q)
+		moves = append(moves, Move{Disk: disk, From: p, To: r})
+		moves = append(moves, Solve(disk-1, q, p, r)...)
+		moves = append(moves, Move{Disk: disk, From: r, To: q})
+		moves = append(moves, scatter(goal, disk-1, p)...)
+	}
+	return moves, nil
+}
+
+// CountBetween returns the number of moves SolveBetween produces for start
+// and goal, without generating them.
+func CountBetween(start, goal []Peg) (uint64, error) {
+	if err := checkBetween(start, goal); err != nil {
+		return 0, err
+	}
+
+	disk := len(start)
+	for disk > 0 && start[disk-1] == goal[disk-1] {
+		disk--
+	}
+	if disk == 0 {
+		return 0, nil
+	}
+
+	p, q := start[disk-1], goal[disk-1]
+	r := other(p, q)
+	once := gatherCost(start, disk-1, r) + 1 + gatherCost(goal, disk-1, r)
+	twice := gatherCost(start, disk-1, q) + MoveCount(disk-1) + 2 + gatherCost(goal, disk-1, p)
+	return min(once, twice), nil
+}
+
+// checkBetween rejects start and goal configurations SolveBetween cannot solve.
+func checkBetween(start, goal []Peg) error {
+	if len(start) != len(goal) {
+		return fmt.Errorf("start has %d discs but goal has %d", len(start), len(goal))
+	}
+	if len(start) > MaxDiscs {
+		return fmt.Errorf("disc count %d must not exceed %d", len(start), MaxDiscs)
+	}
+	for i := range start {
+		if !validPeg(start[i]) || !validPeg(goal[i]) {
+			return fmt.Errorf("disc %d is not on one of the pegs 0, 1 or 2", i+1)
+		}
+	}
+	return nil
+}
+
+// gather appends the optimal moves that stack discs 1..m of state on peg.
+func gather(moves []Move, state []Peg, m int, peg Peg) []Move {
+	for m > 0 && state[m-1] == peg {
+		m--
+	}
+	if m == 0 {
+		return moves
+	}
+	from := state[m-1]
+	spare := other(from, peg)
+	moves = gather(moves, state, m-1, spare)
+	moves = append(moves, Move{Disk: m, From: from, To: peg})
+	return append(moves, Solve(m-1, spare, peg, from)...)
+}
+
+// scatter returns the optimal moves that take discs 1..m from a single stack
+// on peg to their places in state; it is gather played backwards.
+func scatter(state []Peg, m int, peg Peg) []Move {
+	moves := gather(nil, state, m, peg)
+	for i, j := 0, len(moves)-1; i < j; i, j = i+1, j-1 {
+		moves[i], moves[j] = moves[j], moves[i]
+	}
+	for i := range moves {
+		moves[i].From, moves[i].To = moves[i].To, moves[i].From
+	}
+	return moves
+}
+
+// gatherCost returns the number of moves gather would produce.
+func gatherCost(state []Peg, m int, peg Peg) uint64 {
+	var cost uint64
+	for ; m > 0; m-- {
+		if state[m-1] == peg {
+			continue
+		}
+		// Disc m moves once and the m-1 discs above it then follow as a tower.
+		cost += MoveCount(m-1) + 1
+		peg = other(state[m-1], peg)
+	}
+	return cost
+}
+
+func validPeg(p Peg) bool {
+	return p >= 0 && p <= 2
+}
+
+// other returns the third peg, given two distinct pegs.
+func other(a, b Peg) Peg {
+	return 3 - a - b
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package solver
+
+// Split records one level of a Frame–Stewart solution: with Pegs pegs
+// available, the top Parked of Discs discs are first moved aside using every
+// peg, and the remaining discs are then moved with one peg fewer.
+type Split struct {
+	Discs  int
+	Pegs   int
+	Parked int
+}
+
+// FrameStewart returns the presumed-optimal moves that transfer n discs from
+// the from peg to the to peg using every spare peg, together with the split
+// chosen at each level of the main recursion. With a single spare it is the
+// classic solution and no splits are reported.
+func FrameStewart(n int, from, to Peg, spares ...Peg) ([]Move, []Split) {
+	if n <= 0 {
+		return nil, nil
+	}
+	fs := newFrameStewart(n, len(spares)+2)
+	var splits []Split
+	for k, rest := len(spares)+2, n; k > 3 && rest > 1; k-- {
+		t := fs.parked[rest][k]
+		splits = append(splits, Split{Discs: rest, Pegs: k, Parked: t})
+		rest -= t
+	}
+	return fs.solve(nil, 0, n, from, to, spares), splits
+}
+
+// FrameStewartCount returns the number of moves FrameStewart produces for n
+// discs on the given number of pegs.
+func FrameStewartCount(n, pegs int) uint64 {
+	if n <= 0 || pegs < 3 {
+		return 0
+	}
+	return newFrameStewart(n, pegs).cost[n][pegs]
+}
+
+// frameStewart holds, for every disc count up to n and peg count up to k,
+// the minimal Frame–Stewart cost and the number of discs to park for it.
+type frameStewart struct {
+	cost   [][]uint64
+	parked [][]int
+}
+
+func newFrameStewart(n, k int) *frameStewart {
+	fs := &frameStewart{
+		cost:   make([][]uint64, n+1),
+		parked: make([][]int, n+1),
+	}
+	for i := range fs.cost {
+		fs.cost[i] = make([]uint64, k+1)
+		fs.parked[i] = make([]int, k+1)
+	}
+	for i := 1; i <= n; i++ {
+		for j := 3; j <= k; j++ {
+			if j == 3 || i == 1 {
+				fs.cost[i][j] = MoveCount(i)
+				continue
+			}
+			best, parked := uint64(0), 0
+			for t := 1; t < i; t++ {
+				c := 2*fs.cost[t][j] + fs.cost[i-t][j-1]
+				if parked == 0 || c < best {
+					best, parked = c, t
+				}
+			}
+			fs.cost[i][j], fs.parked[i][j] = best, parked
+		}
+	}
+	return fs
+}
+
+// solve appends the moves that transfer discs above+1..above+n; the smaller
+// discs numbered up to above are parked elsewhere and do not move.
+func (fs *frameStewart) solve(moves []Move, above, n int, from, to Peg, spares []Peg) []Move {
+	if n <= 0 {
+		return moves
+	}
+	if len(spares) == 1 || n == 1 {
+		for _, m := range Solve(n, from, to, spares[0]) {
+			m.Disk += above
+			moves = append(moves, m)
+		}
+		return moves
+	}
+	t := fs.parked[n][len(spares)+2]
+	park := spares[0]
+	// Park the top t discs using every peg, move the rest without the parking
+	// peg, then bring the parked discs back on top.
+	moves = fs.solve(moves, above, t, from, park, append([]Peg{to}, spares[1:]...))
+	moves = fs.solve(moves, above+t, n-t, from, to, spares[1:])
+	return fs.solve(moves, above, t, park, to, append([]Peg{from}, spares[1:]...))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package solver
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMoveOutOfRange is returned when a move index falls outside a solution.
+var ErrMoveOutOfRange = errors.New("move index out of range")
+
+// MoveAt returns the k-th move (1-based) of the optimal solution that
+// transfers n discs from the from peg to the to peg, in O(1) time.
+func MoveAt(n int, k uint64, from, to, aux Peg) (Move, error) {
+	if err := checkIndex(n, k, 1); err != nil {
+		return Move{}, err
+	}
+	return moveAt(n, k, [3]Peg{from, to, aux}), nil
+}
+
+// StateAfter returns the position of every disc once the first k moves of
+// the optimal solution have been played; k == 0 is the starting position.
+// The result is indexed by disc, so element i holds the peg of disc i+1.
+//
+// Disc d has moved ceil(floor(k / 2^(d-1)) / 2) times after k moves, which is
+// the binary (Gray-code) characterisation of the puzzle, so the whole
+// configuration is found in O(n) without replaying any moves.
+func StateAfter(n int, k uint64, from, to, aux Peg) ([]Peg, error) {
+	if err := checkIndex(n, k, 0); err != nil {
+		return nil, err
+	}
+	state := make([]Peg, n)
+	for disk := 1; disk <= n; disk++ {
+		q := k >> uint(disk-1)
+		moved := q>>1 + q&1
+		cycle := [3]Peg{from, to, aux}
+		if (n-disk)%2 != 0 {
+			cycle = [3]Peg{from, aux, to}
+		}
+		state[disk-1] = cycle[moved%3]
+	}
+	return state, nil
+}
+
+func checkIndex(n int, k, lowest uint64) error {
+	if n < 0 || n > MaxDiscs {
+		return fmt.Errorf("disc count %d must be between 0 and %d", n, MaxDiscs)
+	}
+	if total := MoveCount(n); k < lowest || k > total {
+		return fmt.Errorf("%w: %d is not between %d and %d", ErrMoveOutOfRange, k, lowest, total)
+	}
+	return nil
+}
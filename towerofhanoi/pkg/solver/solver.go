@@ -0,0 +1,133 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package solver computes Tower of Hanoi move sequences without recursion,
+// so callers can either collect a full solution or stream it one move at a time.
+package solver
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// MaxDiscs is the largest disc count whose move count (2^n - 1) fits in a uint64.
+const MaxDiscs = 64
+
+// Peg identifies a peg by its position on the board, starting at zero.
+type Peg int
+
+// Move is a single step of a solution: the top disc of From is placed on To.
+// Discs are numbered from 1 (the smallest) upwards.
+type Move struct {
+	Disk int
+	From Peg
+	To   Peg
+}
+
+// String renders the move using the peg indices, e.g. "disk 1: 0 -> 2".
+func (m Move) String() string {
+	return fmt.Sprintf("disk %d: %d -> %d", m.Disk, m.From, m.To)
+}
+
+// MoveCount returns the number of moves in the optimal solution for n discs.
+func MoveCount(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	// For n == 64 the shift yields 0 and the subtraction wraps to 2^64 - 1.
+	return 1<<uint(n) - 1
+}
+
+// addCounts adds two move counts, saturating at math.MaxUint64 instead of
+// wrapping around.
+func addCounts(a, b uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return math.MaxUint64
+	}
+	return sum
+}
+
+// Iterator streams the optimal solution for moving n discs from one peg to
+// another. Each move is derived from its index alone, so no intermediate
+// slices are built and memory use stays constant regardless of n.
+type Iterator struct {
+	discs int
+	pegs  [3]Peg
+	next  uint64
+	total uint64
+}
+
+// NewIterator returns an Iterator over the moves that transfer n discs
+// from the from peg to the to peg using aux as the spare.
+func NewIterator(n int, from, to, aux Peg) *Iterator {
+	return &Iterator{
+		discs: n,
+		pegs:  [3]Peg{from, to, aux},
+		next:  1,
+		total: MoveCount(n),
+	}
+}
+
+// Next returns the next move and true, or a zero Move and false once the
+// sequence is exhausted.
+func (it *Iterator) Next() (Move, bool) {
+	if it.total == 0 || it.next == 0 || it.next > it.total {
+		return Move{}, false
+	}
+	m := moveAt(it.discs, it.next, it.pegs)
+	// next wraps to zero after the final move of a 64-disc solution.
+	it.next++
+	return m, true
+}
+
+// Remaining returns how many moves are left in the sequence.
+func (it *Iterator) Remaining() uint64 {
+	if it.next == 0 || it.next > it.total {
+		return 0
+	}
+	return it.total - it.next + 1
+}
+
+// Solve returns every move that transfers n discs from the from peg to the
+// to peg. It materialises the whole sequence, so prefer NewIterator for large n.
+func Solve(n int, from, to, aux Peg) []Move {
+	it := NewIterator(n, from, to, aux)
+	moves := make([]Move, 0, it.Remaining())
+	for m, ok := it.Next(); ok; m, ok = it.Next() {
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+// moveAt returns the k-th move (1-based) of the n-disc solution over pegs
+// ordered as {from, to, aux}. Disc d moves on every step k = (2m+1)*2^(d-1)
+// and always travels round the pegs in the same direction: from -> to -> aux
+// when n-d is even and from -> aux -> to when it is odd.
+func moveAt(n int, k uint64, pegs [3]Peg) Move {
+	disk := bits.TrailingZeros64(k) + 1
+	m := k >> uint(disk)
+	cycle := pegs
+	if (n-disk)%2 != 0 {
+		cycle = [3]Peg{pegs[0], pegs[2], pegs[1]}
+	}
+	return Move{
+		Disk: disk,
+		From: cycle[m%3],
+		To:   cycle[(m+1)%3],
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package solver
+
+import (
+	"fmt"
+	"math"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// recursiveSolve is the textbook recursive solution, used as a reference.
+func recursiveSolve(n int, from, to, aux Peg) []Move {
+	if n == 0 {
+		return nil
+	}
+	var moves []Move
+	moves = append(moves, recursiveSolve(n-1, from, aux, to)...)
+	moves = append(moves, Move{Disk: n, From: from, To: to})
+	moves = append(moves, recursiveSolve(n-1, aux, to, from)...)
+	return moves
+}
+
+var _ = Describe("Solver", func() {
+	It("matches the recursive solution", func() {
+		for n := 1; n <= 12; n++ {
+			Expect(Solve(n, 0, 2, 1)).To(Equal(recursiveSolve(n, 0, 2, 1)), "discs=%d", n)
+			Expect(Solve(n, 2, 1, 0)).To(Equal(recursiveSolve(n, 2, 1, 0)), "discs=%d", n)
+		}
+	})
+
+	It("returns no moves for an empty tower", func() {
+		Expect(Solve(0, 0, 2, 1)).To(BeEmpty())
+		Expect(MoveCount(0)).To(BeZero())
+	})
+
+	It("streams moves without materialising the sequence", func() {
+		it := NewIterator(64, 0, 2, 1)
+		Expect(it.Remaining()).To(Equal(uint64(1<<64 - 1)))
+
+		m, ok := it.Next()
+		Expect(ok).To(BeTrue())
+		Expect(m).To(Equal(Move{Disk: 1, From: 0, To: 1}))
+		Expect(it.Remaining()).To(Equal(uint64(1<<64 - 2)))
+	})
+
+	It("stops after the last move", func() {
+		it := NewIterator(3, 0, 2, 1)
+		count := 0
+		for _, ok := it.Next(); ok; _, ok = it.Next() {
+			count++
+		}
+		Expect(count).To(Equal(7))
+		Expect(it.Remaining()).To(BeZero())
+		_, ok := it.Next()
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("Positions", func() {
+	It("computes the k-th move directly", func() {
+		moves := Solve(10, 0, 2, 1)
+		for i, want := range moves {
+			got, err := MoveAt(10, uint64(i+1), 0, 2, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal(want))
+		}
+	})
+
+	It("rejects indices outside the solution", func() {
+		_, err := MoveAt(3, 0, 0, 2, 1)
+		Expect(err).To(MatchError(ErrMoveOutOfRange))
+		_, err = MoveAt(3, 8, 0, 2, 1)
+		Expect(err).To(MatchError(ErrMoveOutOfRange))
+		_, err = StateAfter(3, 8, 0, 2, 1)
+		Expect(err).To(MatchError(ErrMoveOutOfRange))
+	})
+
+	It("tracks the configuration after every move", func() {
+		const n = 8
+		state := make([]Peg, n)
+		for i, m := range Solve(n, 0, 2, 1) {
+			Expect(state[m.Disk-1]).To(Equal(m.From))
+			state[m.Disk-1] = m.To
+
+			got, err := StateAfter(n, uint64(i+1), 0, 2, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal(state))
+		}
+	})
+
+	It("answers queries on very large towers", func() {
+		state, err := StateAfter(64, MoveCount(64), 0, 2, 1)
+		Expect(err).NotTo(HaveOccurred())
+		for _, p := range state {
+			Expect(p).To(Equal(Peg(2)))
+		}
+	})
+})
+
+// anyMove allows every move between distinct pegs.
+func anyMove(from, to Peg) bool { return true }
+
+// shortestPath finds the length of an optimal solution by breadth-first
+// search over every configuration, used as a reference for small towers.
+// allowed restricts which pegs a disc may travel between.
+func shortestPath(start, goal []Peg, allowed func(from, to Peg) bool) int {
+	key := func(s []Peg) string { return fmt.Sprint(s) }
+	seen := map[string]bool{key(start): true}
+	frontier := [][]Peg{start}
+	for depth := 0; len(frontier) > 0; depth++ {
+		var next [][]Peg
+		for _, s := range frontier {
+			if key(s) == key(goal) {
+				return depth
+			}
+			top := map[Peg]int{}
+			for d := len(s); d >= 1; d-- {
+				top[s[d-1]] = d
+			}
+			for from, d := range top {
+				for to := Peg(0); to < 3; to++ {
+					if t, ok := top[to]; to == from || (ok && t < d) || !allowed(from, to) {
+						continue
+					}
+					n := append([]Peg(nil), s...)
+					n[d-1] = to
+					if !seen[key(n)] {
+						seen[key(n)] = true
+						next = append(next, n)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+	return -1
+}
+
+var _ = Describe("Arbitrary configurations", func() {
+	replay := func(state []Peg, moves []Move) []Peg {
+		state = append([]Peg(nil), state...)
+		for _, m := range moves {
+			for d := 1; d < m.Disk; d++ {
+				Expect(state[d-1]).NotTo(Equal(m.From), "disc %d is covered", m.Disk)
+				Expect(state[d-1]).NotTo(Equal(m.To), "disc %d lands on a smaller disc", m.Disk)
+			}
+			Expect(state[m.Disk-1]).To(Equal(m.From))
+			state[m.Disk-1] = m.To
+		}
+		return state
+	}
+
+	It("matches the classic solution for full towers", func() {
+		moves, err := SolveBetween([]Peg{0, 0, 0, 0}, []Peg{2, 2, 2, 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(moves).To(Equal(Solve(4, 0, 2, 1)))
+	})
+
+	It("finds optimal solutions between every pair of small configurations", func() {
+		var all [][]Peg
+		for code := 0; code < 81; code++ {
+			s := make([]Peg, 4)
+			for i, c := 0, code; i < 4; i, c = i+1, c/3 {
+				s[i] = Peg(c % 3)
+			}
+			all = append(all, s)
+		}
+		for _, start := range all {
+			for _, goal := range all {
+				moves, err := SolveBetween(start, goal)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(replay(start, moves)).To(Equal(goal))
+				Expect(moves).To(HaveLen(shortestPath(start, goal, anyMove)), "%v -> %v", start, goal)
+				count, err := CountBetween(start, goal)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(BeNumerically("==", len(moves)), "%v -> %v", start, goal)
+			}
+		}
+	})
+
+	It("counts the moves between configurations too large to solve", func() {
+		start, goal := make([]Peg, 40), make([]Peg, 40)
+		for i := range goal {
+			goal[i] = 2
+		}
+		count, err := CountBetween(start, goal)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(MoveCount(40)))
+	})
+
+	It("rejects mismatched or unknown pegs", func() {
+		_, err := SolveBetween([]Peg{0, 0}, []Peg{2})
+		Expect(err).To(HaveOccurred())
+		_, err = SolveBetween([]Peg{0, 3}, []Peg{2, 2})
+		Expect(err).To(HaveOccurred())
+		_, err = CountBetween([]Peg{0, 3}, []Peg{2, 2})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Frame–Stewart", func() {
+	// replay plays moves on a board with the given number of pegs, failing on
+	// any illegal move, and returns the final stacks.
+	replay := func(n, pegs int, moves []Move) [][]int {
+		stacks := make([][]int, pegs)
+		for d := n; d >= 1; d-- {
+			stacks[0] = append(stacks[0], d)
+		}
+		for _, m := range moves {
+			from := stacks[m.From]
+			Expect(from).NotTo(BeEmpty())
+			Expect(from[len(from)-1]).To(Equal(m.Disk))
+			if to := stacks[m.To]; len(to) > 0 {
+				Expect(to[len(to)-1]).To(BeNumerically(">", m.Disk))
+			}
+			stacks[m.From] = from[:len(from)-1]
+			stacks[m.To] = append(stacks[m.To], m.Disk)
+		}
+		return stacks
+	}
+
+	It("solves Reve's puzzle in the known number of moves", func() {
+		want := []int{1, 3, 5, 9, 13, 17, 25, 33, 41, 49}
+		for n := 1; n <= len(want); n++ {
+			moves, _ := FrameStewart(n, 0, 1, 2, 3)
+			Expect(moves).To(HaveLen(want[n-1]), "discs=%d", n)
+			Expect(FrameStewartCount(n, 4)).To(BeNumerically("==", want[n-1]))
+			Expect(replay(n, 4, moves)[1]).To(HaveLen(n))
+		}
+	})
+
+	It("moves legally with many pegs", func() {
+		moves, splits := FrameStewart(12, 0, 1, 2, 3, 4, 5)
+		Expect(replay(12, 6, moves)[1]).To(HaveLen(12))
+		Expect(moves).To(HaveLen(int(FrameStewartCount(12, 6))))
+		Expect(splits).NotTo(BeEmpty())
+		Expect(splits[0].Discs).To(Equal(12))
+		Expect(splits[0].Pegs).To(Equal(6))
+	})
+
+	It("falls back to the classic solution on three pegs", func() {
+		moves, splits := FrameStewart(5, 0, 2, 1)
+		Expect(moves).To(Equal(Solve(5, 0, 2, 1)))
+		Expect(splits).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Variants", func() {
+	// replay plays moves on three pegs, failing on any illegal move, and
+	// returns the final position of every disc.
+	replay := func(n int, from Peg, moves []Move, allowed func(from, to Peg) bool) []Peg {
+		state := make([]Peg, n)
+		for i := range state {
+			state[i] = from
+		}
+		for _, m := range moves {
+			Expect(allowed(m.From, m.To)).To(BeTrue(), "%v is not allowed", m)
+			for d := 1; d < m.Disk; d++ {
+				Expect(state[d-1]).NotTo(Equal(m.From), "disc %d is covered", m.Disk)
+				Expect(state[d-1]).NotTo(Equal(m.To), "disc %d lands on a smaller disc", m.Disk)
+			}
+			Expect(state[m.Disk-1]).To(Equal(m.From))
+			state[m.Disk-1] = m.To
+		}
+		return state
+	}
+	tower := func(n int, p Peg) []Peg {
+		s := make([]Peg, n)
+		for i := range s {
+			s[i] = p
+		}
+		return s
+	}
+	order := [3]Peg{0, 1, 2}
+
+	It("solves the cyclic puzzle optimally in both directions", func() {
+		clockwise := func(from, to Peg) bool { return to == (from+1)%3 }
+		for n := 1; n <= 6; n++ {
+			for _, to := range []Peg{1, 2} {
+				moves := SolveCyclic(n, order, 0, to)
+				Expect(replay(n, 0, moves, clockwise)).To(Equal(tower(n, to)))
+				Expect(moves).To(HaveLen(shortestPath(tower(n, 0), tower(n, to), clockwise)))
+				Expect(CyclicCount(n, to == 1)).To(BeNumerically("==", len(moves)))
+			}
+		}
+	})
+
+	It("solves the adjacent-only puzzle optimally between any two pegs", func() {
+		neighbours := func(from, to Peg) bool { return from == 1 || to == 1 }
+		for n := 1; n <= 5; n++ {
+			for from := Peg(0); from < 3; from++ {
+				for to := Peg(0); to < 3; to++ {
+					if from == to {
+						continue
+					}
+					moves := SolveAdjacent(n, order, from, to)
+					Expect(replay(n, from, moves, neighbours)).To(Equal(tower(n, to)))
+					Expect(moves).To(HaveLen(shortestPath(tower(n, from), tower(n, to), neighbours)))
+					Expect(AdjacentCount(n, from != 1 && to != 1)).To(BeNumerically("==", len(moves)))
+				}
+			}
+		}
+	})
+
+	It("keeps the colours of the bicolour tower in order", func() {
+		type disc struct {
+			size int
+			dark bool
+		}
+		for n := 1; n <= 6; n++ {
+			stacks := make([][]disc, 3)
+			for s := n; s >= 1; s-- {
+				stacks[0] = append(stacks[0], disc{s, true}, disc{s, false})
+			}
+			want := append([]disc(nil), stacks[0]...)
+
+			moves := SolveBicolor(n, 0, 2, 1)
+			for _, m := range moves {
+				from := stacks[m.From]
+				Expect(from).NotTo(BeEmpty())
+				top := from[len(from)-1]
+				Expect(top.size).To(Equal(m.Disk))
+				if to := stacks[m.To]; len(to) > 0 {
+					Expect(to[len(to)-1].size).To(BeNumerically(">=", m.Disk))
+				}
+				stacks[m.From] = from[:len(from)-1]
+				stacks[m.To] = append(stacks[m.To], top)
+			}
+			Expect(stacks[2]).To(Equal(want))
+			Expect(moves).To(HaveLen(int(BicolorCount(n))))
+		}
+	})
+
+	It("saturates counts that do not fit in a uint64", func() {
+		Expect(AdjacentCount(40, true)).To(Equal(uint64(12157665459056928800)))
+		Expect(AdjacentCount(41, true)).To(Equal(uint64(math.MaxUint64)))
+		Expect(AdjacentCount(63, false)).To(Equal(uint64(math.MaxUint64)))
+		Expect(CyclicCount(63, true)).To(Equal(uint64(math.MaxUint64)))
+		Expect(CyclicCount(63, false)).To(Equal(uint64(math.MaxUint64)))
+		Expect(BicolorCount(61)).To(Equal(uint64(1)<<63 - 5))
+		Expect(BicolorCount(62)).To(Equal(uint64(math.MaxUint64)))
+	})
+})
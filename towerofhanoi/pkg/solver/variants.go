@@ -0,0 +1,209 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package solver
+
+import "math"
+
+// SolveCyclic returns the optimal moves that transfer n discs from the from
+// peg to the to peg when discs may only travel one way round the pegs:
+// from order[0] to order[1], order[1] to order[2] and order[2] to order[0].
+// from and to must be distinct pegs listed in order.
+func SolveCyclic(n int, order [3]Peg, from, to Peg) []Move {
+	c := &cyclic{order: order}
+	if c.next(from) == to {
+		c.forward(n, from, to)
+	} else {
+		c.backward(n, from, to)
+	}
+	return c.moves
+}
+
+// CyclicCount returns the number of moves SolveCyclic produces for n discs.
+// forward reports whether the to peg directly follows the from peg. Counts
+// that do not fit in a uint64 saturate at math.MaxUint64.
+func CyclicCount(n int, forward bool) uint64 {
+	// q is the cost of moving one step round the cycle, r of moving two.
+	var q, r uint64
+	for i := 1; i <= n; i++ {
+		q, r = addCounts(addCounts(r, r), 1), addCounts(addCounts(r, r), addCounts(q, 2))
+	}
+	if forward {
+		return q
+	}
+	return r
+}
+
+type cyclic struct {
+	order [3]Peg
+	moves []Move
+}
+
+func (c *cyclic) next(p Peg) Peg {
+	for i, o := range c.order {
+		if o == p {
+			return c.order[(i+1)%3]
+		}
+	}
+	return p
+}
+
+// forward moves n discs one step round the cycle, from a to b.
+func (c *cyclic) forward(n int, a, b Peg) {
+	if n == 0 {
+		return
+	}
+	spare := other(a, b)
+	c.backward(n-1, a, spare)
+	c.moves = append(c.moves, Move{Disk: n, From: a, To: b})
+	c.backward(n-1, spare, b)
+}
+
+// backward moves n discs two steps round the cycle, from a to b through the
+// peg in between.
+func (c *cyclic) backward(n int, a, b Peg) {
+	if n == 0 {
+		return
+	}
+	between := other(a, b)
+	c.backward(n-1, a, b)
+	c.moves = append(c.moves, Move{Disk: n, From: a, To: between})
+	c.forward(n-1, b, a)
+	c.moves = append(c.moves, Move{Disk: n, From: between, To: b})
+	c.backward(n-1, a, b)
+}
+
+// SolveAdjacent returns the optimal moves that transfer n discs from the from
+// peg to the to peg when discs may only move between neighbouring pegs, so
+// every move starts or ends on the middle peg order[1]. from and to must be
+// distinct pegs listed in order.
+func SolveAdjacent(n int, order [3]Peg, from, to Peg) []Move {
+	a := &adjacent{middle: order[1]}
+	switch a.middle {
+	case to:
+		a.toMiddle(n, from)
+	case from:
+		a.fromMiddle(n, to)
+	default:
+		a.across(n, from, to)
+	}
+	return a.moves
+}
+
+// AdjacentCount returns the number of moves SolveAdjacent produces for n
+// discs. across reports whether the tower moves between the two end pegs;
+// otherwise it moves between an end and the middle peg. Counts that do not
+// fit in a uint64 saturate at math.MaxUint64.
+func AdjacentCount(n int, across bool) uint64 {
+	var p uint64 = 1
+	for i := 0; i < n; i++ {
+		p = addCounts(p, addCounts(p, p))
+	}
+	if p == math.MaxUint64 {
+		return p
+	}
+	if across {
+		return p - 1
+	}
+	return (p - 1) / 2
+}
+
+type adjacent struct {
+	middle Peg
+	moves  []Move
+}
+
+// across moves n discs between the end pegs from and to.
+func (a *adjacent) across(n int, from, to Peg) {
+	if n == 0 {
+		return
+	}
+	a.across(n-1, from, to)
+	a.moves = append(a.moves, Move{Disk: n, From: from, To: a.middle})
+	a.across(n-1, to, from)
+	a.moves = append(a.moves, Move{Disk: n, From: a.middle, To: to})
+	a.across(n-1, from, to)
+}
+
+// toMiddle moves n discs from the end peg from onto the middle peg.
+func (a *adjacent) toMiddle(n int, from Peg) {
+	if n == 0 {
+		return
+	}
+	end := other(from, a.middle)
+	a.across(n-1, from, end)
+	a.moves = append(a.moves, Move{Disk: n, From: from, To: a.middle})
+	a.toMiddle(n-1, end)
+}
+
+// fromMiddle moves n discs from the middle peg onto the end peg to; it is
+// toMiddle played backwards.
+func (a *adjacent) fromMiddle(n int, to Peg) {
+	if n == 0 {
+		return
+	}
+	end := other(to, a.middle)
+	a.fromMiddle(n-1, end)
+	a.moves = append(a.moves, Move{Disk: n, From: a.middle, To: to})
+	a.across(n-1, end, to)
+}
+
+// SolveBicolor solves the two-colour (double) tower: every size from 1 to n
+// has a dark and a light disc, stacked with the light disc on its dark twin,
+// and discs may rest on others of the same size. The tower must arrive on
+// the to peg with every pair back in its original colour order. Moves name
+// discs by size; the disc moved is always the top one of that size.
+//
+// Moving a pair as a unit reverses its colours, so the bottom pair is sent
+// to the spare peg and on to the target with the smaller pairs moved aside
+// twice, which restores their order, and the rest is solved in the same way.
+func SolveBicolor(n int, from, to, aux Peg) []Move {
+	var moves []Move
+	for ; n > 1; n-- {
+		moves = appendDouble(moves, Solve(n-1, from, to, aux))
+		moves = append(moves, Move{Disk: n, From: from, To: aux}, Move{Disk: n, From: from, To: aux})
+		moves = appendDouble(moves, Solve(n-1, to, from, aux))
+		moves = append(moves, Move{Disk: n, From: aux, To: to}, Move{Disk: n, From: aux, To: to})
+	}
+	if n == 1 {
+		moves = append(moves,
+			Move{Disk: 1, From: from, To: aux},
+			Move{Disk: 1, From: from, To: to},
+			Move{Disk: 1, From: aux, To: to},
+		)
+	}
+	return moves
+}
+
+// BicolorCount returns the number of moves SolveBicolor produces for n sizes,
+// 2^(n+2) - 5. Counts that do not fit in a uint64 saturate at math.MaxUint64.
+func BicolorCount(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	if n+2 >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<uint(n+2) - 5
+}
+
+// appendDouble appends every move twice, moving both discs of a size.
+func appendDouble(moves, single []Move) []Move {
+	for _, m := range single {
+		moves = append(moves, m, m)
+	}
+	return moves
+}